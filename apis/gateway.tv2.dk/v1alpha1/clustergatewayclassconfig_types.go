@@ -0,0 +1,86 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ClusterGatewayClassConfigSpec struct {
+	TemplateValues `json:",inline"`
+
+	// Selects the GatewayClasses this policy applies to by
+	// label. When unset, the policy applies to all GatewayClasses
+	// managed by this controller
+	//
+	// +optional
+	GatewayClassSelector *metav1.LabelSelector `json:"gatewayClassSelector,omitempty"`
+
+	// Selects the namespaces of Gateways this policy applies to
+	// by label. When unset, the policy applies to Gateways in all
+	// namespaces
+	//
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+type ClusterGatewayClassConfigStatus struct {
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=8
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+
+type ClusterGatewayClassConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterGatewayClassConfigSpec   `json:"spec,omitempty"`
+	Status ClusterGatewayClassConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+type ClusterGatewayClassConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterGatewayClassConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterGatewayClassConfig{}, &ClusterGatewayClassConfigList{})
+}
@@ -54,12 +54,139 @@ type ResourceSpec struct {
 	ResourceTemplate   `json:",inline"`
 }
 
+// How list values are merged
+//
+// +kubebuilder:validation:Enum=Replace;Append;UniqueAppend;MergeByKey
+type ValueMergeStrategyType string
+
+const (
+	// Lists from values with higher precedence replace lists with lower precedence
+	ValueMergeStrategyReplace ValueMergeStrategyType = "Replace"
+
+	// Lists from values with higher precedence are appended to lists with lower precedence
+	ValueMergeStrategyAppend ValueMergeStrategyType = "Append"
+
+	// Like Append, but items already in the list are not appended
+	ValueMergeStrategyUniqueAppend ValueMergeStrategyType = "UniqueAppend"
+
+	// Lists of maps are merged by the value of a key, i.e. items
+	// with the same key value are deep-merged and other items
+	// appended
+	ValueMergeStrategyMergeByKey ValueMergeStrategyType = "MergeByKey"
+)
+
+// A merge strategy for list values at a given path
+type ValueMergeStrategy struct {
+	// Dot-separated path of the list value, e.g. 'allowedCIDRs' or 'lb.annotations'
+	Path string `json:"path"`
+
+	Strategy ValueMergeStrategyType `json:"strategy"`
+
+	// Name of the key used to match list items with the MergeByKey strategy
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// Where a blueprint is read from. The blueprint read is used as base
+// of the GatewayClassBlueprint, see BaseBlueprint. Exactly one of the
+// fields must be set
+type BlueprintSource struct {
+	// OCI artifact with a GatewayClassBlueprint as its only layer,
+	// pinned by digest, e.g. 'ghcr.io/org/blueprints@sha256:...'
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[^@]+@sha256:[0-9a-f]{64}$`
+	OCI string `json:"oci,omitempty"`
+
+	// File with a GatewayClassBlueprint, relative to the directory
+	// given to the controller with '--blueprint-source-dir'.
+	// Intended for testing
+	//
+	// +optional
+	File string `json:"file,omitempty"`
+	// File with a GatewayClassBlueprint in a Git repository, pinned
+	// by commit
+	//
+	// +optional
+	Git *GitBlueprintSource `json:"git,omitempty"`
+}
+
+// A file in a Git repository, read over anonymous HTTPS
+type GitBlueprintSource struct {
+	// URL of the repository, e.g. 'https://github.com/org/blueprints.git'
+	//
+	// +kubebuilder:validation:Pattern=`^https://`
+	Repository string `json:"repository"`
+	// Full SHA of the commit to read the file from
+	//
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	Commit string `json:"commit"`
+	// Path of the file in the repository, e.g. 'blueprints/default.yaml'
+	//
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
+// A kind of objects templates may read with the 'lookup' function
+type TemplateLookupKind struct {
+	// Group of the kind, empty for the core API group
+	//
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind, e.g. 'ConfigMap'
+	Kind string `json:"kind"`
+}
+
 type GatewayClassBlueprintSpec struct {
+	// Name of a GatewayClassBlueprint this blueprint is based
+	// on. Templates, helpers and values of the base blueprint are
+	// inherited, with templates and values of this blueprint taking
+	// precedence. An empty template removes the inherited template
+	//
+	// +optional
+	BaseBlueprint string `json:"baseBlueprint,omitempty"`
+
+	// Where the blueprint is read from, with templates and values
+	// of this blueprint taking precedence
+	//
+	// +optional
+	Source *BlueprintSource `json:"source,omitempty"`
+
 	// Template for hardcoded values
 	//
 	// +optional
 	Values TemplateValues `json:"values,omitempty"`
 
+	// Strategies for merging list values from the blueprint and
+	// policies. Lists without a strategy are replaced by values
+	// with higher precedence
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=path
+	ValueMergeStrategies []ValueMergeStrategy `json:"valueMergeStrategies,omitempty"`
+
+	// Kinds of objects templates may read with the 'lookup'
+	// function, in addition to kinds allowed for all blueprints
+	// by the controller
+	//
+	// +optional
+	TemplateLookups []TemplateLookupKind `json:"templateLookups,omitempty"`
+
+	// Include EndpointSlices of backend Services in '.Backends'
+	// when rendering HTTPRoute templates
+	//
+	// +optional
+	BackendEndpointSlices bool `json:"backendEndpointSlices,omitempty"`
+
+	// Named templates, i.e. 'define' blocks, available to all
+	// Gateway and HTTPRoute templates with 'include' or 'template'
+	//
+	// +optional
+	Helpers string `json:"helpers,omitempty"`
+
 	// Template for child resources created from Gateways
 	//
 	// +optional
@@ -82,6 +209,12 @@ type GatewayClassBlueprintStatus struct {
 	// +kubebuilder:validation:MaxItems=8
 	// +kubebuilder:default={{type: "Accepted", status: "Unknown", message: "Waiting for controller", reason: "Pending", lastTransitionTime: "1970-01-01T00:00:00Z"}}
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Revision of the blueprint read from the source, i.e. the
+	// digest of the OCI artifact or of the file, or the Git commit
+	//
+	// +optional
+	SourceRevision string `json:"sourceRevision,omitempty"`
 }
 
 //+kubebuilder:resource:scope=Cluster
@@ -36,10 +36,17 @@ import (
 	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
+// +kubebuilder:validation:XValidation:rule="has(self.targetRef) != has(self.targetSelector)",message="exactly one of targetRef and targetSelector must be set"
 type GatewayClassConfigSpec struct {
 	TemplateValues `json:",inline"`
 
-	TargetRef gatewayv1a2.NamespacedPolicyTargetReference `json:"targetRef"`
+	// +optional
+	TargetRef *gatewayv1a2.NamespacedPolicyTargetReference `json:"targetRef,omitempty"`
+
+	// Selects targets by label, as an alternative to targetRef
+	//
+	// +optional
+	TargetSelector *PolicyTargetSelector `json:"targetSelector,omitempty"`
 }
 
 type GatewayClassConfigStatus struct {
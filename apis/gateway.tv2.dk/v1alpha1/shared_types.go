@@ -33,6 +33,8 @@ package v1alpha1
 
 import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 // Template values - values that will be made available for templates defined in GatewayClassBlueprint.
@@ -52,4 +54,59 @@ type TemplateValues struct {
 	//
 	// +optional
 	Default *apiextensionsv1.JSON `json:"default,omitempty"`
+
+	// Values from keys of ConfigMaps or Secrets. Values are merged
+	// in order after the inline defaults or overrides, i.e. they
+	// have higher precedence than inline values of the same
+	// resource
+	//
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+}
+
+// Reference to a key of a ConfigMap or Secret holding template values
+type ValuesReference struct {
+	// Kind of the referent, ConfigMap or Secret
+	//
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name of the referent. The referent must be in the namespace
+	// of the referring resource, or the controller namespace for
+	// cluster-scoped resources
+	Name string `json:"name"`
+
+	// Key in the referent data
+	Key string `json:"key"`
+
+	// Dot-separated path the value of the key is placed at as a
+	// string, e.g. 'waf.aclArn'. Without a target path, the value
+	// of the key is parsed as YAML and merged
+	//
+	// +optional
+	TargetPath string `json:"targetPath,omitempty"`
+
+	// Whether values are overrides rather than defaults
+	//
+	// +optional
+	Override bool `json:"override,omitempty"`
+
+	// Whether a missing referent or key is ignored
+	//
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Identifies policy targets of a given kind by label. Policies
+// selecting targets apply at the same level of the hierarchy as
+// policies targeting the same kind using targetRef
+type PolicyTargetSelector struct {
+	// Group is the group of the target resources.
+	Group gatewayv1a2.Group `json:"group"`
+
+	// Kind is kind of the target resources, e.g. Namespace or Gateway.
+	Kind gatewayv1a2.Kind `json:"kind"`
+
+	// Selector for target resources by label
+	Selector metav1.LabelSelector `json:"selector"`
 }
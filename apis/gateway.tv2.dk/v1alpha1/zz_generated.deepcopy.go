@@ -39,8 +39,136 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlueprintSource) DeepCopyInto(out *BlueprintSource) {
+	*out = *in
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(GitBlueprintSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlueprintSource.
+func (in *BlueprintSource) DeepCopy() *BlueprintSource {
+	if in == nil {
+		return nil
+	}
+	out := new(BlueprintSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterGatewayClassConfig) DeepCopyInto(out *ClusterGatewayClassConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterGatewayClassConfig.
+func (in *ClusterGatewayClassConfig) DeepCopy() *ClusterGatewayClassConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterGatewayClassConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterGatewayClassConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterGatewayClassConfigList) DeepCopyInto(out *ClusterGatewayClassConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterGatewayClassConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterGatewayClassConfigList.
+func (in *ClusterGatewayClassConfigList) DeepCopy() *ClusterGatewayClassConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterGatewayClassConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterGatewayClassConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterGatewayClassConfigSpec) DeepCopyInto(out *ClusterGatewayClassConfigSpec) {
+	*out = *in
+	in.TemplateValues.DeepCopyInto(&out.TemplateValues)
+	if in.GatewayClassSelector != nil {
+		in, out := &in.GatewayClassSelector, &out.GatewayClassSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterGatewayClassConfigSpec.
+func (in *ClusterGatewayClassConfigSpec) DeepCopy() *ClusterGatewayClassConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterGatewayClassConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterGatewayClassConfigStatus) DeepCopyInto(out *ClusterGatewayClassConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterGatewayClassConfigStatus.
+func (in *ClusterGatewayClassConfigStatus) DeepCopy() *ClusterGatewayClassConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterGatewayClassConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayClassBlueprint) DeepCopyInto(out *GatewayClassBlueprint) {
 	*out = *in
@@ -103,7 +231,22 @@ func (in *GatewayClassBlueprintList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayClassBlueprintSpec) DeepCopyInto(out *GatewayClassBlueprintSpec) {
 	*out = *in
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(BlueprintSource)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Values.DeepCopyInto(&out.Values)
+	if in.ValueMergeStrategies != nil {
+		in, out := &in.ValueMergeStrategies, &out.ValueMergeStrategies
+		*out = make([]ValueMergeStrategy, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateLookups != nil {
+		in, out := &in.TemplateLookups, &out.TemplateLookups
+		*out = make([]TemplateLookupKind, len(*in))
+		copy(*out, *in)
+	}
 	in.GatewayTemplate.DeepCopyInto(&out.GatewayTemplate)
 	in.HTTPRouteTemplate.DeepCopyInto(&out.HTTPRouteTemplate)
 }
@@ -203,7 +346,16 @@ func (in *GatewayClassConfigList) DeepCopyObject() runtime.Object {
 func (in *GatewayClassConfigSpec) DeepCopyInto(out *GatewayClassConfigSpec) {
 	*out = *in
 	in.TemplateValues.DeepCopyInto(&out.TemplateValues)
-	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(v1alpha2.NamespacedPolicyTargetReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(PolicyTargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayClassConfigSpec.
@@ -301,7 +453,16 @@ func (in *GatewayConfigList) DeepCopyObject() runtime.Object {
 func (in *GatewayConfigSpec) DeepCopyInto(out *GatewayConfigSpec) {
 	*out = *in
 	in.TemplateValues.DeepCopyInto(&out.TemplateValues)
-	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(v1alpha2.NamespacedPolicyTargetReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(PolicyTargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfigSpec.
@@ -336,6 +497,21 @@ func (in *GatewayConfigStatus) DeepCopy() *GatewayConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitBlueprintSource) DeepCopyInto(out *GitBlueprintSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitBlueprintSource.
+func (in *GitBlueprintSource) DeepCopy() *GitBlueprintSource {
+	if in == nil {
+		return nil
+	}
+	out := new(GitBlueprintSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 	*out = *in
@@ -397,6 +573,37 @@ func (in *ResourceTemplate) DeepCopy() *ResourceTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTargetSelector) DeepCopyInto(out *PolicyTargetSelector) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTargetSelector.
+func (in *PolicyTargetSelector) DeepCopy() *PolicyTargetSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTargetSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateLookupKind) DeepCopyInto(out *TemplateLookupKind) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateLookupKind.
+func (in *TemplateLookupKind) DeepCopy() *TemplateLookupKind {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateLookupKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateValues) DeepCopyInto(out *TemplateValues) {
 	*out = *in
@@ -410,6 +617,11 @@ func (in *TemplateValues) DeepCopyInto(out *TemplateValues) {
 		*out = new(apiextensionsv1.JSON)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateValues.
@@ -421,3 +633,33 @@ func (in *TemplateValues) DeepCopy() *TemplateValues {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValueMergeStrategy) DeepCopyInto(out *ValueMergeStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValueMergeStrategy.
+func (in *ValueMergeStrategy) DeepCopy() *ValueMergeStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ValueMergeStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesReference) DeepCopyInto(out *ValuesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesReference.
+func (in *ValuesReference) DeepCopy() *ValuesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+)
+
+// Show the effective template values of a Gateway, with the source
+// of each value and the values it masked or merged. Values ignored
+// due to type conflicts are shown last
+func explain(args []string, out io.Writer) error {
+	var inFlags inputFlags
+	var output string
+
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	inFlags.register(fs, false)
+	fs.StringVar(&output, "o", "text", "Output format, 'text' or 'yaml'")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	input, err := inFlags.load()
+	if err != nil {
+		return err
+	}
+
+	origins, conflicts, err := controllers.ExplainOffline(context.Background(), input)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "yaml":
+		data, err := sigsyaml.Marshal(origins)
+		if err != nil {
+			return err
+		}
+		if _, err = out.Write(data); err != nil {
+			return err
+		}
+		for _, conflict := range conflicts {
+			fmt.Fprintf(out, "# conflict: %s\n", conflict)
+		}
+		return nil
+	case "text":
+		for _, origin := range origins {
+			fmt.Fprintf(out, "%s: %s\n  from: %s\n", origin.Path, valueString(origin.Value), origin.Source)
+			// Most recently masked value first, i.e. in order of decreasing precedence
+			for idx := len(origin.Masked) - 1; idx >= 0; idx-- {
+				masked := origin.Masked[idx]
+				if masked.Path != "" {
+					fmt.Fprintf(out, "  masks: %s: %s from %s\n", masked.Path, valueString(masked.Value), masked.Source)
+				} else {
+					fmt.Fprintf(out, "  masks: %s from %s\n", valueString(masked.Value), masked.Source)
+				}
+			}
+			for idx := len(origin.Merged) - 1; idx >= 0; idx-- {
+				merged := origin.Merged[idx]
+				fmt.Fprintf(out, "  merges: %s from %s\n", valueString(merged.Value), merged.Source)
+			}
+		}
+		for _, conflict := range conflicts {
+			fmt.Fprintf(out, "conflict: %s\n", conflict)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+func valueString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
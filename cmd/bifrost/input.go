@@ -0,0 +1,116 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+)
+
+// Flags common to commands reading objects from files
+type inputFlags struct {
+	files         stringSliceFlag
+	gateway       string
+	httpRoute     string
+	resourcesFile string
+}
+
+// Register flags. Fake resources are only relevant when rendering,
+// while a HTTPRoute is only selected when explaining values
+func (f *inputFlags) register(fs *flag.FlagSet, withResources bool) {
+	fs.Var(&f.files, "f", "File with GatewayClassBlueprint, GatewayClass, Gateway, HTTPRoutes, GatewayClassConfigs and GatewayConfigs. May be repeated, '-' reads stdin")
+	fs.StringVar(&f.gateway, "gateway", "", "Gateway as 'namespace/name' or 'name'. Only needed if files contain more than one Gateway")
+	if withResources {
+		fs.StringVar(&f.resourcesFile, "resources", "", "YAML file with fake current child resources, as a map from template name to list of resources. Used as '.Resources' in templates")
+		fs.Func("template-lookup-kinds", "Comma-separated list of kinds, e.g. 'ConfigMap', which templates may read from the files with the 'lookup' function", func(s string) error {
+			for _, kind := range strings.Split(s, ",") {
+				controllers.TemplateLookupKinds = append(controllers.TemplateLookupKinds, schema.ParseGroupKind(kind))
+			}
+			return nil
+		})
+	} else {
+		fs.StringVar(&f.httpRoute, "httproute", "", "HTTPRoute as 'namespace/name' or 'name'. Explain values used for HTTPRoute templates instead of Gateway templates")
+	}
+	fs.StringVar(&controllers.BlueprintSourceDir, "blueprint-source-dir", ".", "Directory of GatewayClassBlueprint files read by blueprints with a 'file' source")
+	fs.StringVar(&controllers.ControllerNamespace, "controller-namespace", "bifrost-gateway-controller-system", "The namespace the controller watch for global policies")
+}
+
+// Read objects and fake resources from files
+func (f *inputFlags) load() (*controllers.OfflineInput, error) {
+	if len(f.files) == 0 {
+		return nil, errors.New("no files given, use '-f'")
+	}
+
+	input := controllers.OfflineInput{}
+	scheme := controllers.OfflineScheme()
+	for _, fname := range f.files {
+		data, err := readFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		objs, err := controllers.DecodeOfflineObjects(scheme, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fname, err)
+		}
+		input.Objects = append(input.Objects, objs...)
+	}
+
+	input.Gateway = parseNamespacedName(f.gateway)
+	input.HTTPRoute = parseNamespacedName(f.httpRoute)
+
+	if f.resourcesFile != "" {
+		data, err := readFile(f.resourcesFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = sigsyaml.Unmarshal(data, &input.Resources); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.resourcesFile, err)
+		}
+	}
+	return &input, nil
+}
+
+// Parse 'namespace/name' or 'name'
+func parseNamespacedName(s string) types.NamespacedName {
+	if ns, name, found := strings.Cut(s, "/"); found {
+		return types.NamespacedName{Namespace: ns, Name: name}
+	}
+	return types.NamespacedName{Name: s}
+}
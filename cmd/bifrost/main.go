@@ -0,0 +1,94 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command bifrost provide tooling for GatewayClassBlueprint authors,
+// e.g. rendering blueprint templates without a cluster.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `Usage: bifrost <command> [flags]
+
+Commands:
+  render    Render GatewayClassBlueprint templates without a cluster
+  explain   Show effective template values of a Gateway and their origin
+
+Use 'bifrost <command> -h' for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = render(os.Args[2:], os.Stdout)
+	case "explain":
+		err = explain(os.Args[2:], os.Stdout)
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Flag accepting multiple values by repeating the flag
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// Read file, with '-' meaning stdin
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
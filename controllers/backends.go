@@ -0,0 +1,235 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// Label of EndpointSlices holding the name of their Service
+const endpointSliceServiceNameLabel = "kubernetes.io/service-name"
+
+var endpointSliceGVK = schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"}
+
+// A backendRef of the parent HTTPRoute resolved to a Service, see TemplateValues
+type TemplateBackendValues struct {
+	// Index of the rule and of the backendRef within the rule,
+	// i.e. the backendRef is
+	// '.HTTPRoute.spec.rules[RuleIndex].backendRefs[BackendRefIndex]'
+	RuleIndex, BackendRefIndex int
+
+	// Namespace and name of the referenced backend
+	Namespace, Name string
+
+	// Port of the backendRef, zero if not given
+	Port int32
+
+	// The referenced Service, nil if not resolved
+	Service map[string]any
+
+	// The port of the Service matching Port, nil if not found
+	ServicePort map[string]any
+
+	// EndpointSlices of the Service. Only set if enabled with
+	// 'backendEndpointSlices' in the GatewayClassBlueprint
+	EndpointSlices []map[string]any
+
+	// Why the backendRef could not be resolved, i.e. 'InvalidKind',
+	// 'RefNotPermitted' or 'BackendNotFound'. Empty if resolved
+	Reason string
+}
+
+// Resolve the backendRefs of all rules of a HTTPRoute to Services.
+// References to Services in other namespaces must be permitted by a
+// ReferenceGrant. EndpointSlices read are returned as dependencies
+// such that changes can be watched
+func resolveBackends(ctx context.Context, r ControllerClient, rt *gatewayapi.HTTPRoute,
+	withEndpointSlices bool) ([]TemplateBackendValues, []lookupDependency, error) {
+	var backends []TemplateBackendValues
+	var deps []lookupDependency
+	for ruleIdx := range rt.Spec.Rules {
+		for refIdx := range rt.Spec.Rules[ruleIdx].BackendRefs {
+			ref := &rt.Spec.Rules[ruleIdx].BackendRefs[refIdx].BackendObjectReference
+			backend := TemplateBackendValues{
+				RuleIndex:       ruleIdx,
+				BackendRefIndex: refIdx,
+				Namespace:       rt.Namespace,
+				Name:            string(ref.Name),
+			}
+			if ref.Namespace != nil {
+				backend.Namespace = string(*ref.Namespace)
+			}
+			if ref.Port != nil {
+				backend.Port = int32(*ref.Port)
+			}
+			if err := resolveBackend(ctx, r, rt, ref, &backend); err != nil {
+				return nil, nil, err
+			}
+			if withEndpointSlices && backend.Service != nil {
+				eps, err := lookupEndpointSlices(ctx, r, backend.Namespace, backend.Name)
+				if err != nil {
+					return nil, nil, err
+				}
+				backend.EndpointSlices = eps
+				deps = append(deps, lookupDependency{endpointSliceGVK, backend.Namespace, ""})
+			}
+			backends = append(backends, backend)
+		}
+	}
+	return backends, deps, nil
+}
+
+// Lookup the Service of a backendRef, setting Reason if it cannot be resolved
+func resolveBackend(ctx context.Context, r ControllerClient, rt *gatewayapi.HTTPRoute,
+	ref *gatewayapi.BackendObjectReference, backend *TemplateBackendValues) error {
+	if !isServiceBackendRef(ref) {
+		backend.Reason = string(gatewayapi.RouteReasonInvalidKind)
+		return nil
+	}
+	granted, err := backendRefGranted(ctx, r, rt, backend.Namespace, backend.Name)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		backend.Reason = string(gatewayapi.RouteReasonRefNotPermitted)
+		return nil
+	}
+
+	var svc corev1.Service
+	if err := r.Client().Get(ctx, types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}, &svc); err != nil {
+		if apierrors.IsNotFound(err) {
+			backend.Reason = string(gatewayapi.RouteReasonBackendNotFound)
+			return nil
+		}
+		return err
+	}
+	if backend.Service, err = objectToMap(&svc); err != nil {
+		return err
+	}
+	ports, _, _ := unstructured.NestedSlice(backend.Service, "spec", "ports")
+	for idx := range svc.Spec.Ports {
+		if svc.Spec.Ports[idx].Port == backend.Port && idx < len(ports) {
+			backend.ServicePort, _ = ports[idx].(map[string]any)
+			break
+		}
+	}
+	return nil
+}
+
+// Whether a backendRef references a Service, the default kind
+func isServiceBackendRef(ref *gatewayapi.BackendObjectReference) bool {
+	return (ref.Group == nil || *ref.Group == "") && (ref.Kind == nil || *ref.Kind == "Service")
+}
+
+// Whether a HTTPRoute may reference a Service, i.e. the Service is in
+// the namespace of the HTTPRoute or a ReferenceGrant in the namespace
+// of the Service permits the reference
+func backendRefGranted(ctx context.Context, r ControllerClient, rt *gatewayapi.HTTPRoute, namespace, name string) (bool, error) {
+	if namespace == rt.Namespace {
+		return true, nil
+	}
+	var grants gatewayapiv1b1.ReferenceGrantList
+	if err := r.Client().List(ctx, &grants, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for idx := range grants.Items {
+		spec := &grants.Items[idx].Spec
+		fromRoute, toService := false, false
+		for _, from := range spec.From {
+			if from.Group == gatewayapi.GroupName && from.Kind == "HTTPRoute" && string(from.Namespace) == rt.Namespace {
+				fromRoute = true
+			}
+		}
+		for _, to := range spec.To {
+			if to.Group == "" && to.Kind == "Service" && (to.Name == nil || string(*to.Name) == name) {
+				toService = true
+			}
+		}
+		if fromRoute && toService {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EndpointSlices of a Service. Listed as unstructured objects, which
+// the manager client reads from the API server rather than its
+// cache. The caller returns the EndpointSlices as a lookup
+// dependency, which makes the lookup watcher start a cluster-wide
+// EndpointSlice informer once any blueprint enables
+// 'backendEndpointSlices'
+func lookupEndpointSlices(ctx context.Context, r ControllerClient, namespace, service string) ([]map[string]any, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(endpointSliceGVK.GroupVersion().WithKind(endpointSliceGVK.Kind + "List"))
+	if err := r.Client().List(ctx, list, client.InNamespace(namespace),
+		client.MatchingLabels{endpointSliceServiceNameLabel: service}); err != nil {
+		return nil, fmt.Errorf("cannot list endpointslices of service %s/%s: %w", namespace, service, err)
+	}
+	eps := make([]map[string]any, 0, len(list.Items))
+	for idx := range list.Items {
+		eps = append(eps, list.Items[idx].Object)
+	}
+	return eps, nil
+}
+
+// ResolvedRefs condition of a HTTPRoute from its resolved backends,
+// reporting the first backendRef not resolved
+func backendsCondition(backends []TemplateBackendValues) *metav1.Condition {
+	for idx := range backends {
+		b := &backends[idx]
+		if b.Reason != "" {
+			return &metav1.Condition{
+				Type:   string(gatewayapi.RouteConditionResolvedRefs),
+				Status: metav1.ConditionFalse,
+				Reason: b.Reason,
+				Message: fmt.Sprintf("backendRef %s/%s of rule %d cannot be resolved: %s",
+					b.Namespace, b.Name, b.RuleIndex, b.Reason),
+			}
+		}
+	}
+	return &metav1.Condition{
+		Type:   string(gatewayapi.RouteConditionResolvedRefs),
+		Status: metav1.ConditionTrue,
+		Reason: string(gatewayapi.RouteReasonResolvedRefs),
+	}
+}
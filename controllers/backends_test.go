@@ -0,0 +1,149 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestResolveBackends(t *testing.T) {
+	ns := func(s string) *gatewayapi.Namespace { n := gatewayapi.Namespace(s); return &n }
+	port := func(p int32) *gatewayapi.PortNumber { n := gatewayapi.PortNumber(p); return &n }
+	kind := func(s string) *gatewayapi.Kind { k := gatewayapi.Kind(s); return &k }
+	svcName := gatewayapi.ObjectName("shared")
+
+	ref := func(namespace *gatewayapi.Namespace, name string, p int32) gatewayapi.HTTPBackendRef {
+		return gatewayapi.HTTPBackendRef{BackendRef: gatewayapi.BackendRef{BackendObjectReference: gatewayapi.BackendObjectReference{
+			Namespace: namespace, Name: gatewayapi.ObjectName(name), Port: port(p)}}}
+	}
+	bucketRef := ref(nil, "bucket", 80)
+	bucketRef.Kind = kind("Bucket")
+
+	rt := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: "foo"},
+		Spec: gatewayapi.HTTPRouteSpec{Rules: []gatewayapi.HTTPRouteRule{
+			{BackendRefs: []gatewayapi.HTTPBackendRef{ref(nil, "web", 80), ref(nil, "missing", 80)}},
+			{BackendRefs: []gatewayapi.HTTPBackendRef{ref(ns("bar"), "shared", 8080), ref(ns("baz"), "private", 80), bucketRef}},
+		}}}
+	objs := []client.Object{
+		rt,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "foo"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "bar"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "private", Namespace: "baz"}},
+		&gatewayapiv1b1.ReferenceGrant{ObjectMeta: metav1.ObjectMeta{Name: "grant", Namespace: "bar"},
+			Spec: gatewayapiv1b1.ReferenceGrantSpec{
+				From: []gatewayapiv1b1.ReferenceGrantFrom{{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Namespace: "foo"}},
+				To:   []gatewayapiv1b1.ReferenceGrantTo{{Group: "", Kind: "Service", Name: &svcName}},
+			}},
+		&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "foo",
+			Labels: map[string]string{endpointSliceServiceNameLabel: "web"}}, AddressType: discoveryv1.AddressTypeIPv4},
+		&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Name: "other-abc", Namespace: "foo",
+			Labels: map[string]string{endpointSliceServiceNameLabel: "other"}}, AddressType: discoveryv1.AddressTypeIPv4},
+	}
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		scheme: scheme,
+	}
+
+	backends, deps, err := resolveBackends(context.Background(), r, rt, true)
+	if err != nil {
+		t.Fatalf("Cannot resolve backends: %v", err)
+	}
+	if len(backends) != 5 {
+		t.Fatalf("Got %d backends, expected 5", len(backends))
+	}
+	for idx, expected := range []struct {
+		ruleIdx, refIdx int
+		namespace, name string
+		reason          string
+	}{
+		{0, 0, "foo", "web", ""},
+		{0, 1, "foo", "missing", "BackendNotFound"},
+		{1, 0, "bar", "shared", ""},
+		{1, 1, "baz", "private", "RefNotPermitted"},
+		{1, 2, "foo", "bucket", "InvalidKind"},
+	} {
+		b := backends[idx]
+		if b.RuleIndex != expected.ruleIdx || b.BackendRefIndex != expected.refIdx || b.Namespace != expected.namespace ||
+			b.Name != expected.name || b.Reason != expected.reason {
+			t.Errorf("Backend %d: got %d/%d %s/%s reason %q, expected %+v", idx, b.RuleIndex, b.BackendRefIndex,
+				b.Namespace, b.Name, b.Reason, expected)
+		}
+		if (b.Service != nil) != (expected.reason == "") {
+			t.Errorf("Backend %d: got service %v, expected resolved %v", idx, b.Service, expected.reason == "")
+		}
+	}
+
+	if nodePort := backends[0].ServicePort["nodePort"]; nodePort != int64(30080) {
+		t.Errorf("Got node port %v, expected 30080", nodePort)
+	}
+	if len(backends[0].EndpointSlices) != 1 || backends[0].EndpointSlices[0]["metadata"].(map[string]any)["name"] != "web-abc" {
+		t.Errorf("Got endpointslices %v, expected web-abc only", backends[0].EndpointSlices)
+	}
+	if backends[2].ServicePort == nil || len(backends[2].EndpointSlices) != 0 {
+		t.Errorf("Got service port %v and endpointslices %v, expected port and no endpointslices",
+			backends[2].ServicePort, backends[2].EndpointSlices)
+	}
+	expectedDeps := []lookupDependency{{endpointSliceGVK, "foo", ""}, {endpointSliceGVK, "bar", ""}}
+	if !reflect.DeepEqual(deps, expectedDeps) {
+		t.Errorf("Got dependencies %v, expected %v", deps, expectedDeps)
+	}
+
+	cond := backendsCondition(backends)
+	if cond.Status != metav1.ConditionFalse || cond.Reason != "BackendNotFound" {
+		t.Errorf("Got condition %s/%s, expected False/BackendNotFound", cond.Status, cond.Reason)
+	}
+	if cond := backendsCondition(backends[:1]); cond.Status != metav1.ConditionTrue {
+		t.Errorf("Got condition status %s, expected True", cond.Status)
+	}
+
+	// EndpointSlices are only read when enabled
+	backends, deps, err = resolveBackends(context.Background(), r, rt, false)
+	if err != nil {
+		t.Fatalf("Cannot resolve backends: %v", err)
+	}
+	if backends[0].EndpointSlices != nil || deps != nil {
+		t.Errorf("Got endpointslices %v and dependencies %v, expected none", backends[0].EndpointSlices, deps)
+	}
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Resolve the chain of base blueprints of a GatewayClassBlueprint into
+// a single blueprint, see inheritBlueprintSpec. Blueprints with a
+// source are first combined with the blueprint given by readSource,
+// with the revision read recorded in the status of the resolved
+// blueprint. The metadata of the blueprint is kept. Missing base
+// blueprints and cycles are errors
+func resolveBlueprint(ctx context.Context, r ControllerClient, gwcb *gwcapi.GatewayClassBlueprint,
+	readSource blueprintSourceReader) (*gwcapi.GatewayClassBlueprint, error) {
+	gwcb, err := withBlueprintSource(ctx, gwcb, readSource)
+	if err != nil {
+		return nil, err
+	}
+	chain := []*gwcapi.GatewayClassBlueprint{gwcb}
+	names := []string{gwcb.Name}
+	for base := gwcb.Spec.BaseBlueprint; base != ""; {
+		if slices.Contains(names, base) {
+			return nil, fmt.Errorf("cycle in base blueprints: %s", strings.Join(append(names, base), " -> "))
+		}
+		var baseBlueprint gwcapi.GatewayClassBlueprint
+		if err := r.Client().Get(ctx, types.NamespacedName{Name: base}, &baseBlueprint); err != nil {
+			return nil, fmt.Errorf("cannot lookup base blueprint %q of %q: %w", base, names[len(names)-1], err)
+		}
+		sourced, err := withBlueprintSource(ctx, &baseBlueprint, readSource)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, sourced)
+		names = append(names, base)
+		base = baseBlueprint.Spec.BaseBlueprint
+	}
+	if len(chain) == 1 {
+		return gwcb, nil
+	}
+
+	spec := chain[len(chain)-1].Spec
+	for idx := len(chain) - 2; idx >= 0; idx-- {
+		if spec, err = inheritBlueprintSpec(&spec, &chain[idx].Spec); err != nil {
+			return nil, fmt.Errorf("cannot inherit from base blueprint of %q: %w", chain[idx].Name, err)
+		}
+	}
+	resolved := gwcb.DeepCopy()
+	resolved.Spec = spec
+	return resolved, nil
+}
+
+// GatewayClasses of this controller using a GatewayClassBlueprint,
+// either directly or through the chain of base blueprints of the
+// blueprint they reference. Base blueprints referenced but missing
+// are included in the chain, such that classes are found when the
+// base is created
+func gatewayClassesUsingBlueprint(ctx context.Context, r ControllerClient, name string) ([]*gatewayapi.GatewayClass, error) {
+	var gwcbList gwcapi.GatewayClassBlueprintList
+	if err := r.Client().List(ctx, &gwcbList); err != nil {
+		return nil, err
+	}
+	bases := make(map[string]string, len(gwcbList.Items))
+	for idx := range gwcbList.Items {
+		bases[gwcbList.Items[idx].Name] = gwcbList.Items[idx].Spec.BaseBlueprint
+	}
+	var gwcList gatewayapi.GatewayClassList
+	if err := r.Client().List(ctx, &gwcList); err != nil {
+		return nil, err
+	}
+	var classes []*gatewayapi.GatewayClass
+	for idx := range gwcList.Items {
+		gwc := &gwcList.Items[idx]
+		ref := gwc.Spec.ParametersRef
+		if !isOurGatewayClass(gwc) || ref == nil || ref.Kind != "GatewayClassBlueprint" || ref.Group != "gateway.tv2.dk" {
+			continue
+		}
+		seen := map[string]bool{}
+		for blueprint := ref.Name; blueprint != "" && !seen[blueprint]; blueprint = bases[blueprint] {
+			if blueprint == name {
+				classes = append(classes, gwc)
+				break
+			}
+			seen[blueprint] = true
+		}
+	}
+	return classes, nil
+}
+
+// Update the Accepted condition of the GatewayClassBlueprint
+// referenced by a GatewayClass from the error resolving it, if any, see
+// resolveBlueprint. The source revision in use is updated for the
+// blueprint and its base blueprints, such that Gateways and HTTPRoutes
+// watching blueprints are rendered again when a source changes
+func updateBlueprintStatus(ctx context.Context, r ControllerClient, gwc *gatewayapi.GatewayClass, resolveErr error) error {
+	ref := gwc.Spec.ParametersRef
+	if ref == nil || ref.Kind != "GatewayClassBlueprint" || ref.Group != "gateway.tv2.dk" {
+		return nil
+	}
+	seen := map[string]bool{}
+	for name := ref.Name; name != "" && !seen[name]; {
+		seen[name] = true
+		var gwcb gwcapi.GatewayClassBlueprint
+		if err := r.Client().Get(ctx, types.NamespacedName{Name: name}, &gwcb); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		before := gwcb.Status.DeepCopy()
+		if name == ref.Name {
+			condition := metav1.Condition{
+				Type:               string(gatewayapi.GatewayClassConditionStatusAccepted),
+				Status:             metav1.ConditionTrue,
+				Reason:             string(gatewayapi.GatewayClassReasonAccepted),
+				ObservedGeneration: gwcb.Generation,
+			}
+			if resolveErr != nil {
+				condition.Status = metav1.ConditionFalse
+				condition.Reason = string(gatewayapi.GatewayClassReasonInvalidParameters)
+				condition.Message = resolveErr.Error()
+			}
+			meta.SetStatusCondition(&gwcb.Status.Conditions, condition)
+		}
+		if gwcb.Spec.Source != nil {
+			if _, revision, err := cachedBlueprintSource(ctx, gwcb.Spec.Source); err == nil {
+				gwcb.Status.SourceRevision = revision
+			}
+		}
+		if !equality.Semantic.DeepEqual(before, &gwcb.Status) {
+			if err := r.Client().Status().Update(ctx, &gwcb); err != nil {
+				return err
+			}
+		}
+		name = gwcb.Spec.BaseBlueprint
+	}
+	return nil
+}
+
+// Predicate for blueprints with changes to their spec or to the
+// revision read from their source, see updateBlueprintStatus
+var blueprintChangedPredicate = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldBlueprint, okOld := e.ObjectOld.(*gwcapi.GatewayClassBlueprint)
+		newBlueprint, okNew := e.ObjectNew.(*gwcapi.GatewayClassBlueprint)
+		return okOld && okNew && oldBlueprint.Status.SourceRevision != newBlueprint.Status.SourceRevision
+	},
+})
+
+// A blueprint combined with the blueprint read from its source, if
+// any, using the blueprint read as base
+func withBlueprintSource(ctx context.Context, gwcb *gwcapi.GatewayClassBlueprint,
+	readSource blueprintSourceReader) (*gwcapi.GatewayClassBlueprint, error) {
+	if gwcb.Spec.Source == nil {
+		return gwcb, nil
+	}
+	sourceSpec, revision, err := readSource(ctx, gwcb.Spec.Source)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read source of blueprint %q: %w", gwcb.Name, err)
+	}
+	out := gwcb.DeepCopy()
+	if out.Spec, err = inheritBlueprintSpec(sourceSpec, &gwcb.Spec); err != nil {
+		return nil, fmt.Errorf("cannot inherit from source of blueprint %q: %w", gwcb.Name, err)
+	}
+	out.Status.SourceRevision = revision
+	return out, nil
+}
+
+// Combine the spec of a blueprint with the spec of its base. Templates
+// of the blueprint replace templates with the same key and an empty
+// template removes the template. Values are deep-merged, see
+// overlayValues. Values references, helpers, lookup kinds and merge
+// strategies of the base are kept, with strategies of the blueprint
+// replacing strategies for the same path
+func inheritBlueprintSpec(base, spec *gwcapi.GatewayClassBlueprintSpec) (gwcapi.GatewayClassBlueprintSpec, error) {
+	var err error
+	out := *spec.DeepCopy()
+
+	if out.Values.Default, err = overlayValues(base.Values.Default, spec.Values.Default); err != nil {
+		return out, fmt.Errorf("cannot merge default values: %w", err)
+	}
+	if out.Values.Override, err = overlayValues(base.Values.Override, spec.Values.Override); err != nil {
+		return out, fmt.Errorf("cannot merge override values: %w", err)
+	}
+	out.Values.ValuesFrom = append(slices.Clone(base.Values.ValuesFrom), out.Values.ValuesFrom...)
+
+	var strategies []gwcapi.ValueMergeStrategy
+	for _, strategy := range base.ValueMergeStrategies {
+		if !slices.ContainsFunc(spec.ValueMergeStrategies, func(s gwcapi.ValueMergeStrategy) bool { return s.Path == strategy.Path }) {
+			strategies = append(strategies, strategy)
+		}
+	}
+	out.ValueMergeStrategies = append(strategies, out.ValueMergeStrategies...)
+
+	lookups := slices.Clone(base.TemplateLookups)
+	for _, kind := range spec.TemplateLookups {
+		if !slices.Contains(lookups, kind) {
+			lookups = append(lookups, kind)
+		}
+	}
+	out.TemplateLookups = lookups
+
+	out.BackendEndpointSlices = base.BackendEndpointSlices || spec.BackendEndpointSlices
+	if base.Helpers != "" && spec.Helpers != "" {
+		out.Helpers = base.Helpers + "\n" + spec.Helpers
+	} else {
+		out.Helpers = base.Helpers + spec.Helpers
+	}
+
+	out.GatewayTemplate.ResourceTemplates = inheritTemplates(base.GatewayTemplate.ResourceTemplates, spec.GatewayTemplate.ResourceTemplates)
+	out.GatewayTemplate.Status = inheritTemplates(base.GatewayTemplate.Status, spec.GatewayTemplate.Status)
+	out.HTTPRouteTemplate.ResourceTemplates = inheritTemplates(base.HTTPRouteTemplate.ResourceTemplates, spec.HTTPRouteTemplate.ResourceTemplates)
+	out.HTTPRouteTemplate.Status = inheritTemplates(base.HTTPRouteTemplate.Status, spec.HTTPRouteTemplate.Status)
+	return out, nil
+}
+
+// Templates of a base blueprint with templates of the blueprint
+// added or replaced. Empty templates remove the template
+func inheritTemplates(base, templates map[string]string) map[string]string {
+	if base == nil && templates == nil {
+		return nil
+	}
+	out := make(map[string]string, len(base)+len(templates))
+	for key, tmpl := range base {
+		out[key] = tmpl
+	}
+	for key, tmpl := range templates {
+		if tmpl == "" {
+			delete(out, key)
+		} else {
+			out[key] = tmpl
+		}
+	}
+	return out
+}
+
+// Deep-merge values of a blueprint into values of its base. Lists and
+// other values are replaced. Null values are kept, such that they
+// remove values from the base as well as from sources with lower
+// precedence when values are looked up, see mergeValueSources
+func overlayValues(base, values *apiextensionsv1.JSON) (*apiextensionsv1.JSON, error) {
+	if base == nil {
+		return values, nil
+	}
+	if values == nil {
+		return base, nil
+	}
+	var baseMap, valuesMap map[string]any
+	if err := json.Unmarshal(base.Raw, &baseMap); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal values of base: %w", err)
+	}
+	if err := json.Unmarshal(values.Raw, &valuesMap); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal values: %w", err)
+	}
+	raw, err := json.Marshal(overlayMap(baseMap, valuesMap))
+	if err != nil {
+		return nil, err
+	}
+	return &apiextensionsv1.JSON{Raw: raw}, nil
+}
+
+// Deep-merge b into a, modifying a in place
+func overlayMap(a, b map[string]any) map[string]any {
+	if a == nil {
+		return b
+	}
+	for k, vb := range b {
+		mb, bIsMap := vb.(map[string]any)
+		ma, aIsMap := a[k].(map[string]any)
+		if bIsMap && aIsMap {
+			a[k] = overlayMap(ma, mb)
+		} else {
+			a[k] = vb
+		}
+	}
+	return a
+}
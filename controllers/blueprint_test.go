@@ -0,0 +1,183 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+func TestResolveBlueprint(t *testing.T) {
+	root := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "root"}}
+	root.Spec.Helpers = `{{ define "root" }}{{ end }}`
+	root.Spec.Values.Default = &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 1, "tags": {"env": "dev", "team": "infra"}, "zones": ["a", "b"]}`)}
+	root.Spec.ValueMergeStrategies = []gwcapi.ValueMergeStrategy{{Path: "zones", Strategy: gwcapi.ValueMergeStrategyAppend}}
+	root.Spec.GatewayTemplate.ResourceTemplates = map[string]string{"lb": "root-lb", "dns": "root-dns"}
+	root.Spec.GatewayTemplate.Status = map[string]string{"template": "root-status"}
+
+	base := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "base"}}
+	base.Spec.BaseBlueprint = "root"
+	base.Spec.HTTPRouteTemplate.ResourceTemplates = map[string]string{"route": "base-route"}
+	base.Spec.TemplateLookups = []gwcapi.TemplateLookupKind{{Kind: "ConfigMap"}}
+
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "cert"}}
+	gwcb.Spec.BaseBlueprint = "base"
+	gwcb.Spec.Helpers = `{{ define "cert" }}{{ end }}`
+	gwcb.Spec.Values.Default = &apiextensionsv1.JSON{Raw: []byte(`{"tags": {"env": "prod", "team": null}, "zones": ["c"]}`)}
+	gwcb.Spec.ValueMergeStrategies = []gwcapi.ValueMergeStrategy{{Path: "zones", Strategy: gwcapi.ValueMergeStrategyUniqueAppend}}
+	gwcb.Spec.GatewayTemplate.ResourceTemplates = map[string]string{"dns": "", "cert": "cert-cert", "lb": "cert-lb"}
+	gwcb.Spec.TemplateLookups = []gwcapi.TemplateLookupKind{{Kind: "ConfigMap"}, {Kind: "Secret"}}
+
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects([]client.Object{root, base}...).Build(),
+		scheme: scheme,
+	}
+
+	resolved, err := resolveBlueprint(context.Background(), r, gwcb, readBlueprintSource)
+	if err != nil {
+		t.Fatalf("Cannot resolve blueprint: %v", err)
+	}
+	if resolved.Name != "cert" {
+		t.Errorf("Got name %q, expected cert", resolved.Name)
+	}
+	spec := &resolved.Spec
+	if expected := map[string]string{"lb": "cert-lb", "cert": "cert-cert"}; !reflect.DeepEqual(spec.GatewayTemplate.ResourceTemplates, expected) {
+		t.Errorf("Got gateway templates %v, expected %v", spec.GatewayTemplate.ResourceTemplates, expected)
+	}
+	if spec.GatewayTemplate.Status["template"] != "root-status" || spec.HTTPRouteTemplate.ResourceTemplates["route"] != "base-route" {
+		t.Errorf("Got status %v and route templates %v, expected inherited", spec.GatewayTemplate.Status, spec.HTTPRouteTemplate.ResourceTemplates)
+	}
+	if !strings.Contains(spec.Helpers, `define "root"`) || !strings.Contains(spec.Helpers, `define "cert"`) {
+		t.Errorf("Got helpers %q, expected helpers of blueprint and base", spec.Helpers)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(spec.Values.Default.Raw, &values); err != nil {
+		t.Fatalf("Cannot unmarshal values: %v", err)
+	}
+	expectedValues := map[string]any{"replicas": 1.0, "tags": map[string]any{"env": "prod", "team": nil}, "zones": []any{"c"}}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("Got values %v, expected %v", values, expectedValues)
+	}
+	if len(spec.ValueMergeStrategies) != 1 || spec.ValueMergeStrategies[0].Strategy != gwcapi.ValueMergeStrategyUniqueAppend {
+		t.Errorf("Got strategies %v, expected UniqueAppend only", spec.ValueMergeStrategies)
+	}
+	if len(spec.TemplateLookups) != 2 {
+		t.Errorf("Got lookup kinds %v, expected ConfigMap and Secret", spec.TemplateLookups)
+	}
+	if len(gwcb.Spec.GatewayTemplate.ResourceTemplates) != 3 {
+		t.Errorf("Blueprint modified while resolving")
+	}
+
+	// Empty templates are removed also when the base has no templates
+	// of the kind
+	if templates := inheritTemplates(nil, map[string]string{"route": "", "dns": "dns"}); !reflect.DeepEqual(templates, map[string]string{"dns": "dns"}) {
+		t.Errorf("Got templates %v, expected empty template removed", templates)
+	}
+
+	// Missing base blueprint
+	gwcb.Spec.BaseBlueprint = "missing"
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, readBlueprintSource); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Got error %v, expected missing base blueprint", err)
+	}
+
+	// Cycle
+	root.Spec.BaseBlueprint = "cert"
+	if err := r.client.Update(context.Background(), root); err != nil {
+		t.Fatalf("Cannot update blueprint: %v", err)
+	}
+	gwcb.Spec.BaseBlueprint = "base"
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, readBlueprintSource); err == nil ||
+		err.Error() != "cycle in base blueprints: cert -> base -> root -> cert" {
+		t.Errorf("Got error %v, expected cycle", err)
+	}
+}
+
+func TestGatewayClassesUsingBlueprint(t *testing.T) {
+	blueprint := func(name, base string) *gwcapi.GatewayClassBlueprint {
+		gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		gwcb.Spec.BaseBlueprint = base
+		return gwcb
+	}
+	class := func(name, blueprint string, controller gatewayapi.GatewayController) *gatewayapi.GatewayClass {
+		return &gatewayapi.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: gatewayapi.GatewayClassSpec{ControllerName: controller,
+				ParametersRef: &gatewayapi.ParametersReference{Group: "gateway.tv2.dk", Kind: "GatewayClassBlueprint", Name: blueprint}},
+		}
+	}
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			blueprint("root", ""), blueprint("base", "root"), blueprint("cert", "base"), blueprint("orphan", "missing"),
+			blueprint("loop", "loop"),
+			class("direct", "root", selfapi.SelfControllerName), class("derived", "cert", selfapi.SelfControllerName),
+			class("other", "root", "example.com/other"), class("orphan", "orphan", selfapi.SelfControllerName),
+			class("loop", "loop", selfapi.SelfControllerName)).Build(),
+		scheme: scheme,
+	}
+	cases := []struct {
+		blueprint string
+		expected  []string
+	}{
+		{"root", []string{"derived", "direct"}},
+		{"cert", []string{"derived"}},
+		{"missing", []string{"orphan"}},
+		{"loop", []string{"loop"}},
+		{"unused", nil},
+	}
+	for _, tc := range cases {
+		classes, err := gatewayClassesUsingBlueprint(context.Background(), r, tc.blueprint)
+		if err != nil {
+			t.Fatalf("Cannot lookup classes of %s: %v", tc.blueprint, err)
+		}
+		var names []string
+		for _, gwc := range classes {
+			names = append(names, gwc.Name)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("%s: got classes %v, expected %v", tc.blueprint, names, tc.expected)
+		}
+	}
+}
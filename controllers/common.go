@@ -36,17 +36,24 @@ import (
 	"errors"
 	"fmt"
 
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logger "sigs.k8s.io/controller-runtime/pkg/log"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
@@ -65,6 +72,7 @@ type ControllerClient interface {
 type ControllerDynClient interface {
 	ControllerClient
 	DynamicClient() dynamic.Interface
+	Recorder() record.EventRecorder
 }
 
 func isOurGatewayClass(gwc *gatewayapi.GatewayClass) bool {
@@ -80,7 +88,10 @@ func lookupGatewayClass(ctx context.Context, r ControllerClient, name gatewayapi
 	return &gwc, nil
 }
 
-func lookupGatewayClassBlueprint(ctx context.Context, r ControllerClient, gwc *gatewayapi.GatewayClass) (*gwcapi.GatewayClassBlueprint, error) {
+// Lookup and resolve the GatewayClassBlueprint of a GatewayClass, see
+// resolveBlueprint. Blueprint sources are read with readSource
+func lookupGatewayClassBlueprint(ctx context.Context, r ControllerClient, gwc *gatewayapi.GatewayClass,
+	readSource blueprintSourceReader) (*gwcapi.GatewayClassBlueprint, error) {
 	if gwc.Spec.ParametersRef == nil {
 		return nil, errors.New("GatewayClass without parameters")
 	}
@@ -94,180 +105,337 @@ func lookupGatewayClassBlueprint(ctx context.Context, r ControllerClient, gwc *g
 		return nil, err
 	}
 
-	return &gwcb, nil
+	return resolveBlueprint(ctx, r, &gwcb, readSource)
 }
 
-// Deep map merge, with 'b' overwriting values in 'a'.  On type conflicts precedence is given to 'a' i.e. no overwrite
-// x and y are concrete versions of a and b
-func merge(a, b any) any {
-	switch x := a.(type) {
-	case map[string]any:
-		y, ok := b.(map[string]any)
-		if !ok {
-			return a
-		}
-		for k, vy := range y { // Copy from 'b' (represented by 'y') into 'a'
-			if va, ok := x[k]; ok {
-				x[k] = merge(va, vy)
-			} else {
-				x[k] = vy
-			}
-		}
-	default:
-		return b
+// A source of template values, i.e. the default or override values of
+// a GatewayClassBlueprint or a policy
+type valueSource struct {
+	// Human readable origin, e.g. 'GatewayClassConfig ns/name (override)'
+	Origin string
+
+	// Raw values, may be nil
+	Values *apiextensionsv1.JSON
+
+	// Whether values are sensitive, i.e. from a Secret
+	Sensitive bool
+}
+
+// Template values looked up for a Gateway or HTTPRoute, see lookupValues
+type valueLookup struct {
+	// Effective template values
+	values map[string]any
+
+	// Policies used, such that their status can be updated
+	policies []*attachedPolicy
+
+	// Descriptions of values ignored due to type conflicts
+	conflicts []string
+
+	// Paths of values from Secrets, which are redacted in logs and events
+	sensitivePaths []string
+}
+
+// Reference to an object as 'namespace/name', or 'name' for cluster-scoped objects
+func objectRef(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
 	}
-	return a
+	return obj.GetNamespace() + "/" + obj.GetName()
 }
 
-// Lookup values from GatewayClassConfig/GatewayConfig CRDs and combine using precedence rules:
+// Origin of values from an object, e.g. 'GatewayConfig foo-infra/foo (default)'
+func valueOrigin(kind string, obj client.Object, field string) string {
+	return fmt.Sprintf("%s %s (%s)", kind, objectRef(obj), field)
+}
+
+// Lookup values from ClusterGatewayClassConfig/GatewayClassConfig/GatewayConfig CRDs and combine using precedence rules:
 // - Values from GatewayClassBlueprint
+// - Values from ClusterGatewayClassConfig selecting the GatewayClass and namespace of the Gateway
 // - Values from GatewayClassConfig in controller namespace (aka. global policies)
 // - Values from GatewayClassConfig in Gateway/HTTPRoute local namespace targeting namespace
 // - Values from GatewayClassConfig in Gateway/HTTPRoute local namespace targeting GatewayClass
 // - Values from GatewayConfig in Gateway/HTTPRoute local namespace, targeting namespace
 // - Values from GatewayConfig in Gateway/HTTPRoute local namespace, targeting Gateway/HTTPRoute resource
+// - Values from GatewayConfig in HTTPRoute namespace, targeting the HTTPRoute. Only when rendering HTTPRoute templates, i.e. route is non-nil
 // Note, defaults are processed top-to-bottom (i.e. later defaults overwrites earlier defaults), while overrides are bottom-to-top (see GEP-713)
+// Policies at the same level are ordered using the GEP-713 conflict resolution rules, see resolvePolicyConflicts.
 //
-// See also doc/extended-configuration-w-policy-attachments.md
+// The policies used are returned such that their status can be
+// updated, together with descriptions of values ignored due to type
+// conflicts and the paths of values from Secrets.
 //
-// FIXME: Fully implement conflict resolution: https://gateway-api.sigs.k8s.io/references/policy-attachment/#conflict-resolution
+// See also doc/extended-configuration-w-policy-attachments.md
+func lookupValues(ctx context.Context, r ControllerClient, gatewayClassName string, gwcb *gwcapi.GatewayClassBlueprint,
+	gwNamespace string, gwName string, route *types.NamespacedName) (*valueLookup, error) {
+	ctx, span := tracer.Start(ctx, "lookupValues", trace.WithAttributes(attrGatewayClass.String(gatewayClassName),
+		attrNamespace.String(gwNamespace), attrName.String(gwName)))
+	defer span.End()
+
+	sources, policies, err := lookupValueSources(ctx, r, gatewayClassName, gwcb, gwNamespace, gwName, route)
+	if err != nil {
+		return nil, err
+	}
+	values, _, conflicts, err := mergeValueSources(sources, gwcb.Spec.ValueMergeStrategies, false)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := sensitivePaths(sources)
+	if err != nil {
+		return nil, err
+	}
+	return &valueLookup{values, policies, conflicts, paths}, nil
+}
+
+// Lookup value sources for lookupValues, ordered such that later
+// sources take precedence over earlier sources. Also returns the
+// policies used
 //
 //nolint:gocyclo // This function have a repeating character and this not as complex as the number of ifs may indicate
-func lookupValues(ctx context.Context, r ControllerClient, gatewayClassName string, gwcb *gwcapi.GatewayClassBlueprint,
-	gwNamespace string, gwName string) (map[string]any, error) {
-	values := map[string]any{}
-	var err error
-
-	// Helper to parse and merge-overwrite values. IMPORTANT: All
-	// values from GatewayClassConfig and GatewayConfigs are
-	// Unmarshalled and hence we will not be modifying original
-	// K8s resources
-	mergeValues := func(src *apiextensionsv1.JSON, existing map[string]any) (map[string]any, error) {
-		if src != nil {
-			newvals := map[string]any{}
-			var ok bool
-			if err = json.Unmarshal(src.Raw, &newvals); err != nil {
-				return nil, fmt.Errorf("cannot unmarshal values: %w", err)
-			}
-			existing, ok = merge(existing, newvals).(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("cannot merge values: %w", err)
-			}
-		}
-		return existing, nil
+func lookupValueSources(ctx context.Context, r ControllerClient, gatewayClassName string, gwcb *gwcapi.GatewayClassBlueprint,
+	gwNamespace string, gwName string, route *types.NamespacedName) ([]valueSource, []*attachedPolicy, error) {
+	// Labels of the resources policies may select
+	gwcLabels, err := lookupLabels(ctx, r, &gatewayapi.GatewayClass{}, types.NamespacedName{Name: gatewayClassName})
+	if err != nil {
+		return nil, nil, err
+	}
+	nsLabels, err := lookupLabels(ctx, r, &corev1.Namespace{}, types.NamespacedName{Name: gwNamespace})
+	if err != nil {
+		return nil, nil, err
+	}
+	gwLabels, err := lookupLabels(ctx, r, &gatewayapi.Gateway{}, types.NamespacedName{Namespace: gwNamespace, Name: gwName})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cgwccSelected, err := lookupClusterGatewayClassConfigs(ctx, r, gwcLabels, nsLabels)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var gwccGlobal gwcapi.GatewayClassConfigList
 	err = r.Client().List(ctx, &gwccGlobal, client.InNamespace(ControllerNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// GatewayClassConfig and GatewayConfig in same namespace as parent resource (e.g. a Gateway resource)
 	var gwccLocal gwcapi.GatewayClassConfigList
 	err = r.Client().List(ctx, &gwccLocal, client.InNamespace(gwNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var gwcLocal gwcapi.GatewayConfigList
 	err = r.Client().List(ctx, &gwcLocal, client.InNamespace(gwNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Select policies that target GatewayClass, parent resource or namespace of parent resource
-	// Note, policies are kept ordered!
-	var gwccFiltered []*gwcapi.GatewayClassConfig
-	var gwcFiltered []*gwcapi.GatewayConfig
+	// Select policies that target GatewayClass, parent resource or
+	// namespace of parent resource. Policies are grouped in levels
+	// of the hierarchy, least specific level first
+	var gwccGlobalGwc, gwccNamespace, gwccLocalGwc, gwcNamespace, gwcGateway []*attachedPolicy
 
 	// Global GatewayClassConfig first
 	for idx := range gwccGlobal.Items {
 		gwcc := &gwccGlobal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "GatewayClass" &&
-			gwcc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			string(gwcc.Spec.TargetRef.Name) == gatewayClassName {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets GatewayClass
+		if policyTargets(ctx, gwcc, gwcc.Spec.TargetRef, gwcc.Spec.TargetSelector, gatewayapi.GroupName, "GatewayClass", "", gatewayClassName, gwcLabels) {
+			gwccGlobalGwc = append(gwccGlobalGwc, gatewayClassConfigPolicy(gwcc)) // gwcc targets GatewayClass
+		}
+	}
+	// Namespace GatewayClassConfig targeting namespace second. Global
+	// GatewayClassConfig may select namespaces by label
+	for idx := range gwccGlobal.Items {
+		gwcc := &gwccGlobal.Items[idx]
+		if gwcc.Namespace != gwNamespace && gwcc.Spec.TargetSelector != nil &&
+			policyTargets(ctx, gwcc, nil, gwcc.Spec.TargetSelector, "", "Namespace", "", gwNamespace, nsLabels) {
+			gwccNamespace = append(gwccNamespace, gatewayClassConfigPolicy(gwcc)) // gwcc selects namespace
 		}
 	}
-	// Namespace GatewayClassConfig targeting namespace second
 	for idx := range gwccLocal.Items {
 		gwcc := &gwccLocal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "Namespace" &&
-			gwcc.Spec.TargetRef.Group == "" &&
-			string(gwcc.Spec.TargetRef.Name) == gwNamespace {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets namespace
+		if policyTargets(ctx, gwcc, gwcc.Spec.TargetRef, gwcc.Spec.TargetSelector, "", "Namespace", "", gwNamespace, nsLabels) {
+			gwccNamespace = append(gwccNamespace, gatewayClassConfigPolicy(gwcc)) // gwcc targets namespace
 		}
 	}
 	// Namespace GatewayClassConfig targeting GatewayClass third
 	for idx := range gwccLocal.Items {
 		gwcc := &gwccLocal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "GatewayClass" &&
-			gwcc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			string(gwcc.Spec.TargetRef.Name) == gatewayClassName {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets GatewayClass
+		if policyTargets(ctx, gwcc, gwcc.Spec.TargetRef, gwcc.Spec.TargetSelector, gatewayapi.GroupName, "GatewayClass", "", gatewayClassName, gwcLabels) {
+			gwccLocalGwc = append(gwccLocalGwc, gatewayClassConfigPolicy(gwcc)) // gwcc targets GatewayClass
 		}
 	}
 	// Namespace GatewayConfig first
 	for idx := range gwcLocal.Items {
 		gwc := &gwcLocal.Items[idx]
-		if gwc.Spec.TargetRef.Kind == "Namespace" &&
-			gwc.Spec.TargetRef.Group == "" &&
-			string(gwc.Spec.TargetRef.Name) == gwNamespace {
-			gwcFiltered = append(gwcFiltered, gwc) // gwcc targets namespace of Gateway
+		if policyTargets(ctx, gwc, gwc.Spec.TargetRef, gwc.Spec.TargetSelector, "", "Namespace", "", gwNamespace, nsLabels) {
+			gwcNamespace = append(gwcNamespace, gatewayConfigPolicy(gwc)) // gwcc targets namespace of Gateway
 		}
 	}
 	// Parent resource GatewayConfig second
 	for idx := range gwcLocal.Items {
 		gwc := &gwcLocal.Items[idx]
-		if gwc.Spec.TargetRef.Kind == "Gateway" &&
-			gwc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			(gwc.Spec.TargetRef.Namespace == nil || string(*gwc.Spec.TargetRef.Namespace) == gwNamespace) &&
-			string(gwc.Spec.TargetRef.Name) == gwName {
-			gwcFiltered = append(gwcFiltered, gwc) // gwcc targets Gateway
+		if policyTargets(ctx, gwc, gwc.Spec.TargetRef, gwc.Spec.TargetSelector, gatewayapi.GroupName, "Gateway", gwNamespace, gwName, gwLabels) {
+			gwcGateway = append(gwcGateway, gatewayConfigPolicy(gwc)) // gwcc targets Gateway
+		}
+	}
+
+	levels := [][]*attachedPolicy{cgwccSelected, gwccGlobalGwc, gwccNamespace, gwccLocalGwc, gwcNamespace, gwcGateway}
+
+	// HTTPRoute GatewayConfig last, since HTTPRoutes are the most specific level
+	if route != nil {
+		rtLabels, err := lookupLabels(ctx, r, &gatewayapi.HTTPRoute{}, *route)
+		if err != nil {
+			return nil, nil, err
+		}
+		var gwcRoute gwcapi.GatewayConfigList
+		err = r.Client().List(ctx, &gwcRoute, client.InNamespace(route.Namespace))
+		if err != nil {
+			return nil, nil, err
 		}
+		var gwcHTTPRoute []*attachedPolicy
+		for idx := range gwcRoute.Items {
+			gwc := &gwcRoute.Items[idx]
+			if policyTargets(ctx, gwc, gwc.Spec.TargetRef, gwc.Spec.TargetSelector, gatewayapi.GroupName, "HTTPRoute", route.Namespace, route.Name, rtLabels) {
+				gwcHTTPRoute = append(gwcHTTPRoute, gatewayConfigPolicy(gwc)) // gwc targets HTTPRoute
+			}
+		}
+		levels = append(levels, gwcHTTPRoute)
+	}
+	var policies []*attachedPolicy
+	for _, level := range levels {
+		resolvePolicyConflicts(level)
+		policies = append(policies, level...)
+	}
+
+	sources := make([]valueSource, 0, 2+2*len(policies))
+	addSources := func(kind string, obj client.Object, values *gwcapi.TemplateValues, override bool) error {
+		srcs, err := templateValueSources(ctx, r, kind, obj, values, override)
+		sources = append(sources, srcs...)
+		return err
 	}
 
 	// Process defaults
 
 	// Blueprint default values are first
-	if values, err = mergeValues(gwcb.Spec.Values.Default, values); err != nil {
-		return nil, fmt.Errorf("while processing blueprint default values for gatewayclass %s: %w", gatewayClassName, err)
+	if err := addSources("GatewayClassBlueprint", gwcb, &gwcb.Spec.Values, false); err != nil {
+		return nil, nil, err
 	}
-	// GatewayClassConfig, ordered, global first
-	for _, pol := range gwccFiltered {
-		if values, err = mergeValues(pol.Spec.Default, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
+	// Policies, least specific level first. Within a level, the policy with highest precedence is last
+	for _, pol := range policies {
+		if err := addSources(pol.kind, pol.obj, pol.values, false); err != nil {
+			return nil, nil, err
 		}
 	}
-	// GatewayConfig, ordered, namespace-targeted first
-	for _, pol := range gwcFiltered {
-		if values, err = mergeValues(pol.Spec.Default, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
+
+	// Process overrides
+
+	// Policies, most specific level first i.e. reverse loop over
+	// levels. Within a level, the policy with highest precedence
+	// is still last
+	for idx := len(levels) - 1; idx >= 0; idx-- {
+		for _, pol := range levels[idx] {
+			if err := addSources(pol.kind, pol.obj, pol.values, true); err != nil {
+				return nil, nil, err
+			}
 		}
 	}
 
-	// Process overrides
+	// Blueprint override values are last since they have highest precedence
+	if err := addSources("GatewayClassBlueprint", gwcb, &gwcb.Spec.Values, true); err != nil {
+		return nil, nil, err
+	}
 
-	// GatewayConfig, ordered, namespace-targeted is first i.e. reverse loop
-	for idx := len(gwcFiltered) - 1; idx >= 0; idx-- {
-		if values, err = mergeValues(gwcFiltered[idx].Spec.Override, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", gwcFiltered[idx].Name, err)
+	return sources, policies, nil
+}
+
+// Lookup ClusterGatewayClassConfigs selecting a GatewayClass and
+// namespace given their labels. Policies with an invalid selector are
+// ignored
+func lookupClusterGatewayClassConfigs(ctx context.Context, r ControllerClient, gwcLabels, nsLabels labels.Set) ([]*attachedPolicy, error) {
+	var cgwccList gwcapi.ClusterGatewayClassConfigList
+	if err := r.Client().List(ctx, &cgwccList); err != nil {
+		return nil, err
+	}
+
+	var selected []*attachedPolicy
+	for idx := range cgwccList.Items {
+		cgwcc := &cgwccList.Items[idx]
+		gwcMatch, err := selectorMatches(cgwcc.Spec.GatewayClassSelector, gwcLabels)
+		if err != nil {
+			logger.FromContext(ctx).Error(err, "invalid gatewayClassSelector", "ClusterGatewayClassConfig", cgwcc.Name)
+			continue
+		}
+		nsMatch, err := selectorMatches(cgwcc.Spec.NamespaceSelector, nsLabels)
+		if err != nil {
+			logger.FromContext(ctx).Error(err, "invalid namespaceSelector", "ClusterGatewayClassConfig", cgwcc.Name)
+			continue
 		}
+		if gwcMatch && nsMatch {
+			selected = append(selected, clusterGatewayClassConfigPolicy(cgwcc))
+		}
+	}
+	return selected, nil
+}
+
+// Test whether a policy targets a resource, either through its
+// targetRef or by selecting the resource labels through its
+// targetSelector. The namespace of the targetRef is only tested
+// when namespace is non-empty. Policies with an invalid selector
+// target nothing
+func policyTargets(ctx context.Context, policy client.Object, ref *gatewayapiv1a2.NamespacedPolicyTargetReference,
+	sel *gwcapi.PolicyTargetSelector, group, kind, namespace, name string, set labels.Set) bool {
+	if ref != nil {
+		return string(ref.Group) == group && string(ref.Kind) == kind && string(ref.Name) == name &&
+			(namespace == "" || ref.Namespace == nil || string(*ref.Namespace) == namespace)
+	}
+	if sel == nil || string(sel.Group) != group || string(sel.Kind) != kind {
+		return false
+	}
+	match, err := selectorMatches(&sel.Selector, set)
+	if err != nil {
+		logger.FromContext(ctx).Error(err, "invalid targetSelector", "policy", objectRef(policy))
+		return false
 	}
+	return match
+}
 
-	// GatewayClassConfig, ordered, global is first i.e. reverse loop
-	for idx := len(gwccFiltered) - 1; idx >= 0; idx-- {
-		if values, err = mergeValues(gwccFiltered[idx].Spec.Override, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", gwccFiltered[idx].Name, err)
+// Labels of an object. An object which is not found has no labels,
+// e.g. namespaces are not known when rendering offline
+func lookupLabels(ctx context.Context, r ControllerClient, obj client.Object, key types.NamespacedName) (labels.Set, error) {
+	if err := r.Client().Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return labels.Set{}, nil
 		}
+		return nil, err
 	}
+	return labels.Set(obj.GetLabels()), nil
+}
 
-	// Blueprint override values are last since they have highest precedence
-	if values, err = mergeValues(gwcb.Spec.Values.Override, values); err != nil {
-		return nil, fmt.Errorf("while processing blueprint override values for gatewayclass %s: %w", gatewayClassName, err)
+// Lookup a Namespace for use in templates. A Namespace which does not
+// exist, e.g. when rendering without a cluster, is represented by its
+// name only
+func lookupNamespaceMap(ctx context.Context, r ControllerClient, name string) (map[string]any, error) {
+	var ns corev1.Namespace
+	if err := r.Client().Get(ctx, types.NamespacedName{Name: name}, &ns); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		ns.Name = name
 	}
+	return objectToMap(&ns)
+}
 
-	return values, nil
+// Test labels against a selector. A nil selector matches everything
+func selectorMatches(selector *metav1.LabelSelector, set labels.Set) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(set), nil
 }
 
 func lookupGateway(ctx context.Context, r ControllerClient, name gatewayapi.ObjectName, namespace string) (*gatewayapi.Gateway, error) {
@@ -307,35 +475,43 @@ func unstructuredToGVR(r ControllerClient, u *unstructured.Unstructured) (*schem
 	}, isNamespaced, nil
 }
 
-// Apply an unstructured object using server-side apply
+// Apply an unstructured object using server-side apply. With dryRun
+// the patch is validated and the resulting object returned, but not
+// persisted
 func patchUnstructured(ctx context.Context, r ControllerDynClient, us *unstructured.Unstructured,
-	gvr *schema.GroupVersionResource, namespace *string) error {
+	gvr *schema.GroupVersionResource, namespace *string, dryRun bool) (*unstructured.Unstructured, error) {
 	jsonData, err := json.Marshal(us.Object)
 	if err != nil {
-		return fmt.Errorf("unable to marshal unstructured to json %w", err)
+		return nil, fmt.Errorf("unable to marshal unstructured to json %w", err)
 	}
 
 	force := true
+	opts := metav1.PatchOptions{
+		Force:        &force,
+		FieldManager: string(selfapi.SelfControllerName),
+	}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
 
-	metricPatchApply.Inc()
+	ctx, span := tracer.Start(ctx, "patchUnstructured", trace.WithAttributes(gvrAttribute(gvr), attrName.String(us.GetName()),
+		attrDryRun.Bool(dryRun)))
+	defer span.End()
+	if namespace != nil {
+		span.SetAttributes(attrNamespace.String(*namespace))
+	}
+
+	var result *unstructured.Unstructured
 	if namespace != nil {
 		dynamicClient := r.DynamicClient().Resource(*gvr).Namespace(*namespace)
-		_, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, metav1.PatchOptions{
-			Force:        &force,
-			FieldManager: string(selfapi.SelfControllerName),
-		})
+		result, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, opts)
 	} else {
 		dynamicClient := r.DynamicClient().Resource(*gvr)
-		_, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, metav1.PatchOptions{
-			Force:        &force,
-			FieldManager: string(selfapi.SelfControllerName),
-		})
+		result, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, opts)
 	}
 
-	if err != nil {
-		metricPatchApplyErrs.Inc()
-	}
-	return err
+	spanError(span, err)
+	return result, err
 }
 
 func PtrTo[T any](val T) *T {
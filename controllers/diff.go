@@ -0,0 +1,190 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Diff reported for resources which do not exist and would be created
+const diffCreated = "resource would be created"
+
+// Maximum number of changes included in a single Event message
+const diffMaxEventChanges = 20
+
+// Fields maintained by the API server and thus ignored when comparing resources
+var diffIgnoredPaths = []string{
+	"metadata.creationTimestamp",
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"status",
+}
+
+// Field-level diff between the current resource and the result of a
+// dry-run apply. Changes are formatted as 'path: old -> new' with '<none>'
+// marking missing values, sorted by path
+func diffResource(current, desired *unstructured.Unstructured) []string {
+	if current == nil {
+		return []string{diffCreated}
+	}
+	if desired == nil {
+		return nil
+	}
+	changes := diffValues("", current.Object, desired.Object, nil)
+	sort.Strings(changes)
+	return changes
+}
+
+func diffValues(path string, a, b any, changes []string) []string {
+	for _, ignored := range diffIgnoredPaths {
+		if path == ignored {
+			return changes
+		}
+	}
+
+	mapA, okA := a.(map[string]any)
+	mapB, okB := b.(map[string]any)
+	if okA && okB {
+		keys := map[string]bool{}
+		for k := range mapA {
+			keys[k] = true
+		}
+		for k := range mapB {
+			keys[k] = true
+		}
+		for k := range keys {
+			changes = diffValues(joinPath(path, k), mapA[k], mapB[k], changes)
+		}
+		return changes
+	}
+
+	sliceA, okA := a.([]any)
+	sliceB, okB := b.([]any)
+	if okA && okB && len(sliceA) == len(sliceB) {
+		for idx := range sliceA {
+			changes = diffValues(fmt.Sprintf("%s[%d]", path, idx), sliceA[idx], sliceB[idx], changes)
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, diffValueString(a), diffValueString(b)))
+	}
+	return changes
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func diffValueString(v any) string {
+	if v == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// Last dry-run diff recorded for each parent resource, such that
+// Events are only recorded when the diff changes
+var (
+	dryRunDiffsLock sync.Mutex
+	dryRunDiffs     = map[types.NamespacedName]string{}
+)
+
+// Record dry-run diffs of child resources as Events on the parent
+// resource, unless the diff is unchanged since last recorded. String
+// values found at redacted value paths, including values from
+// Secrets, are masked
+func recordDryRunDiff(recorder record.EventRecorder, parent client.Object, templates []*ResourceTemplateState, values *TemplateValues) {
+	var messages []string
+	for _, tmpl := range templates {
+		for resIdx := range tmpl.Resources {
+			res := &tmpl.Resources[resIdx]
+			if len(res.Diff) == 0 {
+				continue
+			}
+			for idx := range res.Diff {
+				res.Diff[idx] = redactText(res.Diff[idx], values.Values, values.redactedPaths())
+			}
+			diff := res.Diff
+			if len(diff) > diffMaxEventChanges {
+				diff = append(diff[:diffMaxEventChanges:diffMaxEventChanges], fmt.Sprintf("... %d more changes", len(res.Diff)-diffMaxEventChanges))
+			}
+			messages = append(messages, fmt.Sprintf("dry-run: %s/%s %q from template %q would change: %s",
+				res.Rendered.GetAPIVersion(), res.Rendered.GetKind(), res.Rendered.GetName(), tmpl.TemplateName, strings.Join(diff, "; ")))
+		}
+	}
+	if len(messages) == 0 {
+		messages = []string{"dry-run: no resources would change"}
+	}
+
+	key := client.ObjectKeyFromObject(parent)
+	summary := strings.Join(messages, "\n")
+	dryRunDiffsLock.Lock()
+	unchanged := dryRunDiffs[key] == summary
+	dryRunDiffs[key] = summary
+	dryRunDiffsLock.Unlock()
+	if unchanged {
+		return
+	}
+	for _, msg := range messages {
+		recorder.Event(parent, corev1.EventTypeNormal, EventReasonDryRunDiff, msg)
+	}
+}
+
+// Forget the last dry-run diff of a parent resource, e.g. when dry-run
+// is disabled or the resource is deleted
+func forgetDryRunDiff(key types.NamespacedName) {
+	dryRunDiffsLock.Lock()
+	defer dryRunDiffsLock.Unlock()
+	delete(dryRunDiffs, key)
+}
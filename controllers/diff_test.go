@@ -0,0 +1,122 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestDiffResource(t *testing.T) {
+	current := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":            "foo",
+			"resourceVersion": "1",
+			"labels":          map[string]any{"app": "foo", "old": "label"},
+		},
+		"spec": map[string]any{
+			"replicas": int64(1),
+			"ports":    []any{map[string]any{"port": int64(80)}},
+		},
+		"status": map[string]any{"ready": true},
+	}}
+	desired := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":            "foo",
+			"resourceVersion": "2",
+			"labels":          map[string]any{"app": "foo"},
+		},
+		"spec": map[string]any{
+			"replicas": int64(2),
+			"ports":    []any{map[string]any{"port": int64(443)}},
+			"paused":   false,
+		},
+	}}
+
+	diff := diffResource(current, desired)
+	expected := []string{
+		"metadata.labels.old: \"label\" -> <none>",
+		"spec.paused: <none> -> false",
+		"spec.ports[0].port: 80 -> 443",
+		"spec.replicas: 1 -> 2",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("Diff error, got %q, expected %q", diff, expected)
+	}
+
+	if diff := diffResource(current, current); len(diff) != 0 {
+		t.Fatalf("Diff of identical resources, got %q, expected none", diff)
+	}
+
+	if diff := diffResource(nil, desired); len(diff) != 1 || diff[0] != diffCreated {
+		t.Fatalf("Diff of new resource, got %q, expected %q", diff, diffCreated)
+	}
+}
+
+func TestRecordDryRunDiff(t *testing.T) {
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "diff-test", Name: "gw"}}
+	defer forgetDryRunDiff(client.ObjectKeyFromObject(gw))
+	rendered := &unstructured.Unstructured{}
+	rendered.SetAPIVersion("v1")
+	rendered.SetKind("Service")
+	rendered.SetName("foo")
+	tmpl := &ResourceTemplateState{TemplateName: "svc", Resources: []ResourceComposite{{Rendered: rendered}}}
+	values := &TemplateValues{}
+	recorder := record.NewFakeRecorder(10)
+
+	expectEvents := func(expected int) {
+		t.Helper()
+		if len(recorder.Events) != expected {
+			t.Fatalf("Got %d events, expected %d", len(recorder.Events), expected)
+		}
+		for range expected {
+			<-recorder.Events
+		}
+	}
+
+	recordDryRunDiff(recorder, gw, []*ResourceTemplateState{tmpl}, values)
+	expectEvents(1)
+	recordDryRunDiff(recorder, gw, []*ResourceTemplateState{tmpl}, values)
+	expectEvents(0)
+	tmpl.Resources[0].Diff = []string{"spec.replicas: 1 -> 2"}
+	recordDryRunDiff(recorder, gw, []*ResourceTemplateState{tmpl}, values)
+	expectEvents(1)
+	forgetDryRunDiff(client.ObjectKeyFromObject(gw))
+	recordDryRunDiff(recorder, gw, []*ResourceTemplateState{tmpl}, values)
+	expectEvents(1)
+}
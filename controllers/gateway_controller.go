@@ -33,23 +33,36 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
 )
 
 // Used to requeue when a resource is missing a dependency
@@ -60,11 +73,18 @@ type GatewayReconciler struct {
 	client    client.Client
 	scheme    *runtime.Scheme
 	dynClient dynamic.Interface
+	recorder  record.EventRecorder
+
+	// Objects read by templates using 'lookup'
+	lookups *lookupWatcher
 }
 
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 func (r *GatewayReconciler) Client() client.Client {
 	return r.client
@@ -78,22 +98,176 @@ func (r *GatewayReconciler) DynamicClient() dynamic.Interface {
 	return r.dynClient
 }
 
+func (r *GatewayReconciler) Recorder() record.EventRecorder {
+	return r.recorder
+}
+
 func NewGatewayController(mgr ctrl.Manager, config *rest.Config) *GatewayReconciler {
 	r := &GatewayReconciler{
 		client:    mgr.GetClient(),
 		scheme:    mgr.GetScheme(),
 		dynClient: dynamic.NewForConfigOrDie(config),
+		recorder:  mgr.GetEventRecorderFor(eventSourceName),
 	}
 	return r
 }
 
 func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&gatewayapi.Gateway{}).
-		Complete(r)
+	// Policies may select Namespaces and GatewayClasses by label,
+	// hence label changes trigger reconciles. The Namespace and
+	// GatewayClass, including their annotations, and attached
+	// HTTPRoutes are also available to templates. Policies
+	// may also reference values in ConfigMaps and Secrets. Templates
+	// are rendered again when a blueprint or its bases change,
+	// including the revision read from their sources
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayapi.Gateway{}, builder.WithPredicates(inventoryChangePredicate)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysInNamespace),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&gatewayapi.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysOfClass),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysOfBlueprint),
+			builder.WithPredicates(blueprintChangedPredicate)).
+		Watches(&gatewayapi.HTTPRoute{}, handler.EnqueueRequestsFromMapFunc(gatewaysOfHTTPRoute),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysUsingValuesFrom("ConfigMap")),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysUsingValuesFrom("Secret")),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.lookups = newLookupWatcher(mgr.GetCache(), c)
+	return nil
+}
+
+// Map a Namespace to the Gateways in the namespace and the Gateways
+// which HTTPRoutes in the namespace attach to. The latter are needed
+// since listener namespace selectors match on the labels of the route
+// namespace
+func (r *GatewayReconciler) gatewaysInNamespace(ctx context.Context, ns client.Object) []reconcile.Request {
+	var gwList gatewayapi.GatewayList
+	if err := r.Client().List(ctx, &gwList, client.InNamespace(ns.GetName())); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list Gateways", "namespace", ns.GetName())
+		return nil
+	}
+	var rtList gatewayapi.HTTPRouteList
+	if err := r.Client().List(ctx, &rtList, client.InNamespace(ns.GetName())); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list HTTPRoutes", "namespace", ns.GetName())
+		return nil
+	}
+	seen := map[types.NamespacedName]bool{}
+	requests := make([]reconcile.Request, 0, len(gwList.Items))
+	add := func(req reconcile.Request) {
+		if !seen[req.NamespacedName] {
+			seen[req.NamespacedName] = true
+			requests = append(requests, req)
+		}
+	}
+	for idx := range gwList.Items {
+		add(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gwList.Items[idx])})
+	}
+	for idx := range rtList.Items {
+		for _, req := range gatewaysOfHTTPRoute(ctx, &rtList.Items[idx]) {
+			add(req)
+		}
+	}
+	return requests
+}
+
+// Map a ConfigMap or Secret referenced by policies or
+// GatewayClassBlueprints to the Gateways which may use its
+// values. References from the controller namespace may affect all
+// Gateways, otherwise only Gateways in the namespace
+func (r *GatewayReconciler) gatewaysUsingValuesFrom(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		referenced, err := isValuesFromSource(ctx, r, kind, obj)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "cannot lookup references", "kind", kind, "object", objectRef(obj))
+			return nil
+		}
+		if !referenced {
+			return nil
+		}
+		var gwList gatewayapi.GatewayList
+		opts := []client.ListOption{}
+		if obj.GetNamespace() != ControllerNamespace {
+			opts = append(opts, client.InNamespace(obj.GetNamespace()))
+		}
+		if err := r.Client().List(ctx, &gwList, opts...); err != nil {
+			log.FromContext(ctx).Error(err, "cannot list Gateways")
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(gwList.Items))
+		for idx := range gwList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gwList.Items[idx])})
+		}
+		return requests
+	}
+}
+
+// Map a HTTPRoute to its parent Gateways
+func gatewaysOfHTTPRoute(_ context.Context, obj client.Object) []reconcile.Request {
+	rt, ok := obj.(*gatewayapi.HTTPRoute)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, pRef := range rt.Spec.ParentRefs {
+		if (pRef.Group != nil && *pRef.Group != gatewayapi.GroupName) || (pRef.Kind != nil && *pRef.Kind != "Gateway") {
+			continue
+		}
+		parent := types.NamespacedName{Namespace: rt.Namespace, Name: string(pRef.Name)}
+		if pRef.Namespace != nil {
+			parent.Namespace = string(*pRef.Namespace)
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: parent})
+	}
+	return requests
+}
+
+// Map a GatewayClassBlueprint to the Gateways of the GatewayClasses
+// using it, see gatewayClassesUsingBlueprint
+func (r *GatewayReconciler) gatewaysOfBlueprint(ctx context.Context, gwcb client.Object) []reconcile.Request {
+	classes, err := gatewayClassesUsingBlueprint(ctx, r, gwcb.GetName())
+	if err != nil {
+		log.FromContext(ctx).Error(err, "cannot lookup GatewayClasses of blueprint", "blueprint", gwcb.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, gwc := range classes {
+		requests = append(requests, r.gatewaysOfClass(ctx, gwc)...)
+	}
+	return requests
+}
+
+// Map a GatewayClass to the Gateways using the class
+func (r *GatewayReconciler) gatewaysOfClass(ctx context.Context, gwc client.Object) []reconcile.Request {
+	var gwList gatewayapi.GatewayList
+	if err := r.Client().List(ctx, &gwList); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list Gateways")
+		return nil
+	}
+	var requests []reconcile.Request
+	for idx := range gwList.Items {
+		if string(gwList.Items[idx].Spec.GatewayClassName) == gwc.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gwList.Items[idx])})
+		}
+	}
+	return requests
 }
 
 func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "reconcileGateway", trace.WithAttributes(attrNamespace.String(req.Namespace), attrName.String(req.Name)))
+	defer span.End()
+
+	result, err := r.reconcile(ctx, req)
+	spanError(span, err)
+	return result, err
+}
+
+func (r *GatewayReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var requeue bool
 
 	logger := log.FromContext(ctx)
@@ -102,6 +276,12 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	var gw gatewayapi.Gateway
 
 	if err := r.Client().Get(ctx, req.NamespacedName, &gw); err != nil {
+		if apierrors.IsNotFound(err) {
+			deleteGatewayMetrics(req.NamespacedName)
+			forgetDryRunDiff(req.NamespacedName)
+			forgetPolicyTarget(policyTarget{"Gateway", req.NamespacedName})
+			_ = r.lookups.track(req.NamespacedName, nil)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
@@ -109,15 +289,42 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			r.recorder.Eventf(&gw, corev1.EventTypeWarning, EventReasonDependencyMissing,
+				"GatewayClass %q not found", gw.Spec.GatewayClassName)
+		}
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, client.IgnoreNotFound(err)
 	}
 
 	if !isOurGatewayClass(gwc) {
+		deleteGatewayMetrics(req.NamespacedName)
+		forgetPolicyTarget(policyTarget{"Gateway", req.NamespacedName})
+		_ = r.lookups.track(req.NamespacedName, nil)
 		return ctrl.Result{}, nil
 	}
 
-	gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc)
+	// In dry-run mode child resources are not changed, instead changes
+	// are reported. Status, inventory and metrics are not updated
+	dryRun := gw.Annotations[selfapi.DryRunAnnotation] == "true"
+	if dryRun {
+		logger.Info("dry-run, child resources will not be changed")
+	} else {
+		forgetDryRunDiff(req.NamespacedName)
+	}
+
+	// Gateways are reported as not ready in metrics, unless
+	// reconcile completes
+	var reconciled bool
+	defer func() {
+		if !reconciled && !dryRun {
+			setGatewayNotReadyMetrics(req.NamespacedName, gwc.Name)
+		}
+	}()
+
+	gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc, cachedBlueprintSource)
 	if err != nil {
+		r.recorder.Eventf(&gw, corev1.EventTypeWarning, EventReasonDependencyMissing,
+			"parameters for GatewayClass %q not found: %v", gwc.ObjectMeta.Name, err)
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, fmt.Errorf("parameters for GatewayClass %q not found: %w", gwc.ObjectMeta.Name, err)
 	}
 
@@ -127,29 +334,66 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 	gwRoutes := filterHTTPRoutesForGateway(&gw, routes)
 	union, isect := combineHostnames(&gw, gwRoutes)
+	attachedRoutes, err := attachedHTTPRoutes(ctx, r, &gw, routes)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot look up attached routes: %w", err)
+	}
 
 	// Prepare Gateway resource for use in templates by converting to map[string]any
-	gatewayMap, err := objectToMap(&gw)
+	gatewayMap, err := parentToMap(&gw)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot convert gateway to map: %w", err)
 	}
+	nsMap, err := lookupNamespaceMap(ctx, r, gw.Namespace)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot lookup namespace: %w", err)
+	}
+	gwcMap, err := objectToMap(gwc)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot convert gatewayclass to map: %w", err)
+	}
+	gwcbMap, err := blueprintToMap(gwcb)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot convert gatewayclassblueprint to map: %w", err)
+	}
 
-	values, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.ObjectMeta.Namespace, gw.ObjectMeta.Name)
+	lookup, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.ObjectMeta.Namespace, gw.ObjectMeta.Name, nil)
+	var missing *missingValuesReferenceError
+	if errors.As(err, &missing) {
+		r.recorder.Event(&gw, corev1.EventTypeWarning, EventReasonDependencyMissing, missing.Error())
+		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot lookup values: %w", err)
 	}
+	for _, conflict := range lookup.conflicts {
+		r.recorder.Event(&gw, corev1.EventTypeWarning, EventReasonValueConflict, conflict)
+	}
+	if err := updatePolicyStatus(ctx, r, policyTarget{"Gateway", req.NamespacedName}, lookup.policies); err != nil {
+		logger.Error(err, "unable to update policy status")
+	}
 
 	// Setup template variables context
 	templateValues := TemplateValues{
-		Gateway: &gatewayMap,
-		Values:  values,
+		Gateway:          &gatewayMap,
+		HTTPRoutes:       attachedRoutes,
+		Namespace:        nsMap,
+		GatewayNamespace: nsMap,
+		GatewayClass:     gwcMap,
+		Blueprint:        gwcbMap,
+		Values:           lookup.values,
 		Hostnames: TemplateHostnameValues{
 			Union:        union,
 			Intersection: isect,
 		},
+		sensitivePaths: lookup.sensitivePaths,
+		lookup:         newTemplateLookup(ctx, r, gwcb),
 	}
 
-	templates, err := parseTemplates(gwcb.Spec.GatewayTemplate.ResourceTemplates)
+	_, parseSpan := tracer.Start(ctx, "parseTemplates", trace.WithAttributes(attrGatewayClass.String(gwc.Name)))
+	templates, err := parseTemplates(gwc.Name, gwcb.Spec.Helpers, gwcb.Spec.GatewayTemplate.ResourceTemplates)
+	spanError(parseSpan, err)
+	parseSpan.End()
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot parse templates: %w", err)
 	}
@@ -165,18 +409,25 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	for attempt := 0; attempt < len(templates); attempt++ {
 		logger.Info("reconcile loop", "attempt", attempt)
 		isFinalAttempt := attempt == len(templates)-1
+		attemptCtx, attemptSpan := tracer.Start(ctx, "renderAttempt", trace.WithAttributes(attrAttempt.Int(attempt)))
 
 		templateValues.Resources = buildResourceValues(templates)
 
-		renderedNum, existsNum = renderTemplates(ctx, r, &gw, templates, &templateValues, isFinalAttempt)
+		renderedNum, existsNum = renderTemplates(attemptCtx, r, &gw, templates, &templateValues, isFinalAttempt)
 		logger.Info("Rendered", "rendered", renderedNum, "exists", existsNum)
 
-		if err = applyTemplates(ctx, r, &gw, templates); err != nil {
+		if err = applyTemplates(attemptCtx, r, &gw, templates, dryRun); err != nil {
 			errStatus = fmt.Errorf("unable to apply templates: %w", err)
+			spanError(attemptSpan, err)
 		}
+		attemptSpan.End()
 	}
 
 	requeue = (renderedNum != len(templates))
+	recordApplyErrors(r.recorder, &gw, templates)
+	if dryRun {
+		recordDryRunDiff(r.recorder, &gw, templates, &templateValues)
+	}
 	logger.Info("ending reconcile loop", "renderedNum", renderedNum, "totalNum", len(templates), "requeue", requeue)
 
 	beforeStatusUpdate := gw.DeepCopy()
@@ -187,11 +438,12 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if found {
 		statusUpdateOK = false
 		templateValues.Resources = buildResourceValues(templates) // Needed in case of a single-pass render loop above
-		if tmpl, errs := parseSingleTemplate("status", tmplStr); errs != nil {
+		if tmpl, errs := parseSingleTemplate("status", gwcb.Spec.Helpers, tmplStr); errs != nil {
 			logger.Info("unable to parse status template", "temporary error", errs)
 		} else {
 			if statusMap, errs := template2maps(tmpl, &templateValues); errs != nil {
-				logger.Info("unable to render status template", "temporary error", errs, "template", tmplStr, "values", templateValues)
+				logger.Info("unable to render status template", "temporary error", errs)
+				debugLogger(ctx, &gw).Info("status template render error", "template", tmplStr, "values", redactTemplateValues(&templateValues))
 			} else {
 				gw.Status.Addresses = []gatewayapi.GatewayStatusAddress{}
 				_, found := statusMap[0]["addresses"] // FIXME, more addresses?
@@ -211,6 +463,18 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		requeue = true
 	}
 
+	if err := r.lookups.track(req.NamespacedName, templateValues.lookup.deps); err != nil {
+		logger.Error(err, "unable to watch objects read by templates")
+	}
+
+	if dryRun {
+		if requeue && errStatus == nil {
+			logger.Info("requeue - not all resources rendered")
+			return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
+		}
+		return ctrl.Result{}, errStatus
+	}
+
 	// TODO: Consider if we can set listener status conditions calculated from child resources
 	for _, listener := range gw.Spec.Listeners {
 		var status *gatewayapi.ListenerStatus
@@ -280,14 +544,33 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		Reason:             string(gatewayapi.GatewayReasonReady),
 		ObservedGeneration: gw.ObjectMeta.Generation})
 
+	setGatewayMetrics(req.NamespacedName, gwc.Name, childResourceCount(templates), status == metav1.ConditionTrue)
+
+	wasReady := meta.IsStatusConditionTrue(beforeStatusUpdate.Status.Conditions, string(gatewayapi.GatewayConditionReady))
+	if status == metav1.ConditionTrue && !wasReady {
+		r.recorder.Event(&gw, corev1.EventTypeNormal, EventReasonReady, "Gateway is ready")
+	} else if status != metav1.ConditionTrue && wasReady {
+		r.recorder.Event(&gw, corev1.EventTypeWarning, EventReasonNotReady, "Gateway is no longer ready")
+	}
+
 	if !equality.Semantic.DeepEqual(beforeStatusUpdate.Status, gw.Status) {
-		if err := r.Client().Status().Update(ctx, &gw); err != nil {
+		statusCtx, statusSpan := tracer.Start(ctx, "updateStatus")
+		err := r.Client().Status().Update(statusCtx, &gw)
+		spanError(statusSpan, err)
+		statusSpan.End()
+		if err != nil {
 			logger.Error(err, "unable to update Gateway status")
 			return ctrl.Result{}, err
 		}
 	}
 
-	if requeue {
+	if err := updateInventory(ctx, r, &gw, buildInventory(templates, gw.Namespace, "")); err != nil {
+		logger.Error(err, "unable to update Gateway inventory")
+		return ctrl.Result{}, err
+	}
+
+	reconciled = errStatus == nil
+	if requeue && errStatus == nil {
 		logger.Info("requeue - not all resources updated")
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
 	}
@@ -347,12 +630,7 @@ func filterHTTPRoutesForGateway(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTT
 	rtOut := make([]*gatewayapi.HTTPRoute, 0, len(rtList))
 	for _, rt := range rtList {
 		for _, pRef := range rt.Spec.ParentRefs {
-			if (pRef.Group != nil && *pRef.Group != gatewayapi.Group(gatewayapi.GroupName)) ||
-				(pRef.Kind != nil && *pRef.Kind != gatewayapi.Kind("Gateway")) ||
-				(pRef.Namespace != nil && *pRef.Namespace != gatewayapi.Namespace(gw.ObjectMeta.Namespace)) ||
-				// Unspecified namespace means use HTTPRoute namespace
-				(pRef.Namespace == nil && rt.ObjectMeta.Namespace != gw.ObjectMeta.Namespace) ||
-				(pRef.Name != gatewayapi.ObjectName(gw.ObjectMeta.Name)) {
+			if !parentRefIsGateway(gw, rt, &pRef) {
 				// Skip as ParentRef does not refer to Gateway
 				continue
 			}
@@ -366,6 +644,16 @@ func filterHTTPRoutesForGateway(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTT
 	return rtOut
 }
 
+// Whether a parentRef of a HTTPRoute references a Gateway
+func parentRefIsGateway(gw *gatewayapi.Gateway, rt *gatewayapi.HTTPRoute, pRef *gatewayapi.ParentReference) bool {
+	return (pRef.Group == nil || *pRef.Group == gatewayapi.Group(gatewayapi.GroupName)) &&
+		(pRef.Kind == nil || *pRef.Kind == gatewayapi.Kind("Gateway")) &&
+		(pRef.Namespace == nil || *pRef.Namespace == gatewayapi.Namespace(gw.ObjectMeta.Namespace)) &&
+		// Unspecified namespace means use HTTPRoute namespace
+		(pRef.Namespace != nil || rt.ObjectMeta.Namespace == gw.ObjectMeta.Namespace) &&
+		pRef.Name == gatewayapi.ObjectName(gw.ObjectMeta.Name)
+}
+
 // Lookup all HTTPRoutes
 func lookupHTTPRoutes(ctx context.Context, r ControllerClient) ([]*gatewayapi.HTTPRoute, error) {
 	var rtList gatewayapi.HTTPRouteList
@@ -34,6 +34,7 @@ package controllers
 import (
 	"context"
 	"regexp"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -44,6 +45,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
 
 	"sigs.k8s.io/gateway-api/conformance/utils/kubernetes"
@@ -259,6 +261,15 @@ var _ = Describe("Gateway controller", func() {
 			}
 			Expect(childGateway.ObjectMeta.OwnerReferences).To(ContainElement(expectedOwnerReference))
 
+			By("Publishing an inventory of child resources")
+			Eventually(func() string {
+				gwRead := &gatewayapi.Gateway{}
+				if err := k8sClient.Get(ctx, gwNN, gwRead); err != nil {
+					return ""
+				}
+				return gwRead.ObjectMeta.Annotations["gateway.tv2.dk/inventory"]
+			}, timeout, interval).Should(ContainSubstring(`"template":"childGateway"`))
+
 			By("Updating conditions")
 
 			// Set child status to not ready
@@ -393,6 +404,22 @@ var _ = Describe("Gateway controller non-ready resources", func() {
 				}
 				return true
 			}, 5*time.Second, interval).Should(BeTrue())
+
+			By("Emitting an event for the template render error")
+			Eventually(func() bool {
+				events := &corev1.EventList{}
+				if err := k8sClient.List(ctx, events, client.InNamespace(gw.ObjectMeta.Namespace)); err != nil {
+					return false
+				}
+				for idx := range events.Items {
+					ev := &events.Items[idx]
+					if ev.InvolvedObject.Kind == "Gateway" && ev.InvolvedObject.Name == gw.ObjectMeta.Name &&
+						ev.Reason == EventReasonRenderError && strings.Contains(ev.Message, "configMapTestIntermediate1") {
+						return true
+					}
+				}
+				return false
+			}, timeout, interval).Should(BeTrue())
 		})
 	})
 })
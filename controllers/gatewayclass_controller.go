@@ -35,21 +35,32 @@ import (
 	"context"
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logger "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 )
 
 // GatewayClassReconciler reconciles a GatewayClass object
 type GatewayClassReconciler struct {
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses,verbs=get;list;watch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses/finalizers,verbs=update
@@ -58,6 +69,10 @@ type GatewayClassReconciler struct {
 //+kubebuilder:rbac:groups=gateway.tv2.dk,resources=gatewayclassblueprints/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.tv2.dk,resources=gatewayclassblueprints/finalizers,verbs=update
 
+//+kubebuilder:rbac:groups=gateway.tv2.dk,resources=clustergatewayclassconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.tv2.dk,resources=clustergatewayclassconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.tv2.dk,resources=clustergatewayclassconfigs/finalizers,verbs=update
+
 //+kubebuilder:rbac:groups=gateway.tv2.dk,resources=gatewayclassconfigs,verbs=get;list;watch
 //+kubebuilder:rbac:groups=gateway.tv2.dk,resources=gatewayclassconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.tv2.dk,resources=gatewayclassconfigs/finalizers,verbs=update
@@ -76,19 +91,39 @@ func (r *GatewayClassReconciler) Scheme() *runtime.Scheme {
 
 func NewGatewayClassController(mgr ctrl.Manager) *GatewayClassReconciler {
 	r := &GatewayClassReconciler{
-		client: mgr.GetClient(),
-		scheme: mgr.GetScheme(),
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor(eventSourceName),
 		//dynClient: dynamic.NewForConfigOrDie(ctrl.GetConfigOrDie()),
 	}
 	return r
 }
 
 func (r *GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The status of GatewayClasses and their blueprints depend on
+	// the blueprint and its base blueprints
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&gatewayapi.GatewayClass{}).
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.gatewayClassesOfBlueprint),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
 
+// Map a GatewayClassBlueprint to the GatewayClasses using it, see
+// gatewayClassesUsingBlueprint
+func (r *GatewayClassReconciler) gatewayClassesOfBlueprint(ctx context.Context, gwcb client.Object) []reconcile.Request {
+	classes, err := gatewayClassesUsingBlueprint(ctx, r, gwcb.GetName())
+	if err != nil {
+		logger.FromContext(ctx).Error(err, "cannot lookup GatewayClasses of blueprint", "blueprint", gwcb.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(classes))
+	for _, gwc := range classes {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(gwc)})
+	}
+	return requests
+}
+
 func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logger.FromContext(ctx)
 
@@ -104,10 +139,17 @@ func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, nil
 	}
 
-	_, err = lookupGatewayClassBlueprint(ctx, r, gwc)
+	// Blueprint sources are only read here, Gateways and HTTPRoutes
+	// use the blueprints read
+	_, err = lookupGatewayClassBlueprint(ctx, r, gwc, resolveBlueprintSource)
+	if statusErr := updateBlueprintStatus(ctx, r, gwc, err); statusErr != nil {
+		log.Error(statusErr, "unable to update GatewayClassBlueprint status")
+	}
 	if err != nil {
 		valid = false
 		errWhyInvalid = fmt.Errorf("blueprint for GatewayClass %q not found", gwc.ObjectMeta.Name)
+		r.recorder.Eventf(gwc, corev1.EventTypeWarning, EventReasonDependencyMissing,
+			"cannot lookup GatewayClassBlueprint: %v", err)
 	}
 
 	if valid {
@@ -122,6 +164,7 @@ func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Type:               string(gatewayapi.GatewayClassConditionStatusAccepted),
 			Status:             "False",
 			Reason:             string(gatewayapi.GatewayClassReasonInvalidParameters),
+			Message:            err.Error(),
 			ObservedGeneration: gwc.ObjectMeta.Generation})
 	}
 
@@ -0,0 +1,560 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1" //nolint:gosec // Git object names are SHA-1
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Client used to fetch from Git repositories
+var gitHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Maximum size of packs fetched from Git repositories
+const maxGitPackSize = 32 << 20
+
+// Maximum total size of the objects inflated from a pack, including
+// objects resolved from deltas
+const maxGitObjectsSize = 64 << 20
+
+var errGitObjectsTooLarge = errors.New("objects in pack exceed the size limit")
+
+// Git object types, as numbered in packs
+const (
+	gitObjCommit   = 1
+	gitObjTree     = 2
+	gitObjBlob     = 3
+	gitObjTag      = 4
+	gitObjOfsDelta = 6
+	gitObjRefDelta = 7
+)
+
+var gitObjTypeNames = map[int]string{
+	gitObjCommit: "commit",
+	gitObjTree:   "tree",
+	gitObjBlob:   "blob",
+	gitObjTag:    "tag",
+}
+
+type gitObject struct {
+	data    []byte
+	objType int
+}
+
+// Read a blueprint from a file in a Git repository at a commit. The
+// commit is fetched without history with the smart HTTP protocol,
+// version 2, and anonymous access. The revision is the commit
+func readGitBlueprint(ctx context.Context, src *gwcapi.GitBlueprintSource) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	if !strings.HasPrefix(src.Repository, "https://") {
+		return nil, "", fmt.Errorf("git repository %q is not an https URL", src.Repository)
+	}
+	if _, err := hex.DecodeString(src.Commit); err != nil || len(src.Commit) != 2*sha1.Size {
+		return nil, "", fmt.Errorf("git commit %q is not a full SHA", src.Commit)
+	}
+	if !fs.ValidPath(src.Path) || src.Path == "." {
+		return nil, "", fmt.Errorf("git path %q is not a valid path in a repository", src.Path)
+	}
+	objects, err := fetchGitCommit(ctx, strings.TrimSuffix(src.Repository, "/"), src.Commit)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot fetch commit %s of %q: %w", src.Commit, src.Repository, err)
+	}
+	data, err := gitFileAtCommit(objects, src.Commit, src.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("commit %s of %q: %w", src.Commit, src.Repository, err)
+	}
+	spec, err := decodeSourceBlueprint(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("file %q in commit %s of %q: %w", src.Path, src.Commit, src.Repository, err)
+	}
+	return spec, src.Commit, nil
+}
+
+// Find a file in the tree of a commit. Objects are keyed by their
+// name, i.e. their content is verified by the lookup
+func gitFileAtCommit(objects map[string]gitObject, commit, path string) ([]byte, error) {
+	obj, found := objects[commit]
+	if !found || obj.objType != gitObjCommit {
+		return nil, errors.New("commit not found")
+	}
+	header, _, _ := bytes.Cut(obj.data, []byte("\n"))
+	tree, found := strings.CutPrefix(string(header), "tree ")
+	if !found {
+		return nil, errors.New("commit without tree")
+	}
+	names := strings.Split(path, "/")
+	for idx, name := range names {
+		obj, found = objects[tree]
+		if !found || obj.objType != gitObjTree {
+			return nil, fmt.Errorf("tree %s not found", tree)
+		}
+		mode, entry, err := gitTreeEntry(obj.data, name)
+		if err != nil {
+			return nil, err
+		}
+		if entry == "" {
+			return nil, fmt.Errorf("file %q not found", path)
+		}
+		if idx < len(names)-1 {
+			if mode != "40000" {
+				return nil, fmt.Errorf("%q is not a directory", strings.Join(names[:idx+1], "/"))
+			}
+			tree = entry
+			continue
+		}
+		if !strings.HasPrefix(mode, "100") {
+			return nil, fmt.Errorf("%q is not a regular file", path)
+		}
+		obj, found = objects[entry]
+		if !found || obj.objType != gitObjBlob {
+			return nil, fmt.Errorf("blob %s not found", entry)
+		}
+		return obj.data, nil
+	}
+	return nil, fmt.Errorf("file %q not found", path)
+}
+
+// Mode and object name of an entry in a tree, an empty name if there
+// is no such entry. Entries are '<mode> <name>\0<binary object name>'
+func gitTreeEntry(data []byte, name string) (mode, object string, err error) {
+	for len(data) > 0 {
+		header, rest, found := bytes.Cut(data, []byte{0})
+		if !found || len(rest) < sha1.Size {
+			return "", "", errors.New("malformed tree")
+		}
+		entryMode, entryName, _ := strings.Cut(string(header), " ")
+		if entryName == name {
+			return entryMode, hex.EncodeToString(rest[:sha1.Size]), nil
+		}
+		data = rest[sha1.Size:]
+	}
+	return "", "", nil
+}
+
+// Fetch a commit and its tree from a repository, without history
+func fetchGitCommit(ctx context.Context, repository, commit string) (map[string]gitObject, error) {
+	capabilities, err := gitCapabilities(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	var request bytes.Buffer
+	writePktLine(&request, "command=fetch")
+	if format, found := capabilities["object-format"]; found && format != "sha1" {
+		return nil, fmt.Errorf("unsupported object format %q", format)
+	}
+	request.WriteString("0001")
+	writePktLine(&request, "no-progress")
+	writePktLine(&request, "ofs-delta")
+	writePktLine(&request, "want "+commit)
+	if strings.Contains(" "+capabilities["fetch"]+" ", " shallow ") {
+		writePktLine(&request, "deepen 1")
+	}
+	writePktLine(&request, "done")
+	request.WriteString("0000")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, repository+"/git-upload-pack", &request)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+	req.Header.Set("Accept", "application/x-git-upload-pack-result")
+	req.Header.Set("Git-Protocol", "version=2")
+	resp, err := gitHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	pack, err := readGitPackfileSection(bufio.NewReader(io.LimitReader(resp.Body, maxGitPackSize+1)))
+	if err != nil {
+		return nil, err
+	}
+	if len(pack) > maxGitPackSize {
+		return nil, fmt.Errorf("pack larger than %d bytes", maxGitPackSize)
+	}
+	return parseGitPack(pack, maxGitObjectsSize)
+}
+
+// Capabilities advertised by a repository for protocol version 2,
+// e.g. 'fetch' mapped to 'shallow filter'
+func gitCapabilities(ctx context.Context, repository string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repository+"/info/refs?service=git-upload-pack", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Git-Protocol", "version=2")
+	resp, err := gitHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body := bufio.NewReader(io.LimitReader(resp.Body, maxBlueprintSourceSize))
+	capabilities := map[string]string{}
+	version2 := false
+	for {
+		line, special, err := readPktLine(body)
+		if err != nil {
+			return nil, err
+		}
+		if special == "0000" {
+			// Smart HTTP servers precede the advertisement by a
+			// service line and a flush
+			if version2 {
+				break
+			}
+			continue
+		}
+		text := strings.TrimSuffix(string(line), "\n")
+		switch {
+		case text == "version 2":
+			version2 = true
+		case version2:
+			name, value, _ := strings.Cut(text, "=")
+			capabilities[name] = value
+		}
+	}
+	if _, found := capabilities["fetch"]; !found {
+		return nil, errors.New("server does not support fetch with protocol version 2")
+	}
+	return capabilities, nil
+}
+
+// Write a pkt-line, i.e. the data prefixed by its length plus four as
+// four hex digits
+func writePktLine(w *bytes.Buffer, line string) {
+	fmt.Fprintf(w, "%04x%s\n", len(line)+5, line)
+}
+
+// Read a pkt-line, returning its data, or the special packet, e.g.
+// '0000' for flush and '0001' for delimiter
+func readPktLine(r *bufio.Reader) (data []byte, special string, err error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, "", fmt.Errorf("cannot read pkt-line: %w", err)
+	}
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed pkt-line length %q", header)
+	}
+	if length < 4 {
+		return nil, string(header[:]), nil
+	}
+	data = make([]byte, length-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, "", fmt.Errorf("cannot read pkt-line: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte("ERR ")) {
+		return nil, "", fmt.Errorf("server error: %s", strings.TrimSpace(string(data[4:])))
+	}
+	return data, "", nil
+}
+
+// Read the pack of a fetch response, skipping other sections, e.g.
+// 'shallow-info'. Pack data is multiplexed on band 1, with progress on
+// band 2 and errors on band 3
+func readGitPackfileSection(r *bufio.Reader) ([]byte, error) {
+	inPack := false
+	var pack []byte
+	for {
+		line, special, err := readPktLine(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case special == "0000" || special == "0002":
+			if !inPack {
+				return nil, errors.New("response without pack")
+			}
+			return pack, nil
+		case special != "":
+			continue // Delimiter between sections
+		case !inPack:
+			inPack = strings.TrimSuffix(string(line), "\n") == "packfile"
+		case len(line) == 0:
+			continue
+		case line[0] == 1:
+			if len(pack)+len(line)-1 > maxGitPackSize {
+				return nil, fmt.Errorf("pack larger than %d bytes", maxGitPackSize)
+			}
+			pack = append(pack, line[1:]...)
+		case line[0] == 3:
+			return nil, fmt.Errorf("server error: %s", strings.TrimSpace(string(line[1:])))
+		}
+	}
+}
+
+// Parse a pack into objects keyed by name, failing if the objects
+// inflated, including those resolved from deltas, exceed limit bytes
+// in total. Deltas are resolved against objects in the pack, i.e. thin
+// packs are not supported
+func parseGitPack(pack []byte, limit int) (map[string]gitObject, error) {
+	if len(pack) < 12+sha1.Size || string(pack[:4]) != "PACK" {
+		return nil, errors.New("malformed pack")
+	}
+	content, trailer := pack[:len(pack)-sha1.Size], pack[len(pack)-sha1.Size:]
+	if sum := sha1.Sum(content); !bytes.Equal(sum[:], trailer) { //nolint:gosec // Pack checksums are SHA-1
+		return nil, errors.New("pack checksum mismatch")
+	}
+	if version := binary.BigEndian.Uint32(pack[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	count := binary.BigEndian.Uint32(pack[8:12])
+	if int64(count) > int64(len(content)) {
+		return nil, errors.New("malformed pack")
+	}
+
+	type refDelta struct {
+		base  string
+		delta []byte
+	}
+	objects := map[string]gitObject{}
+	byOffset := map[int]gitObject{}
+	var refDeltas []refDelta
+	r := bytes.NewReader(content[12:])
+	for range count {
+		offset := len(content) - r.Len()
+		objType, size, err := readGitObjectHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var base gitObject
+		var baseName string
+		switch objType {
+		case gitObjOfsDelta:
+			distance, err := readGitDeltaDistance(r)
+			var found bool
+			if base, found = byOffset[offset-distance]; err != nil || !found {
+				return nil, errors.New("malformed delta in pack")
+			}
+		case gitObjRefDelta:
+			name := make([]byte, sha1.Size)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, errors.New("truncated pack")
+			}
+			baseName = hex.EncodeToString(name)
+		case gitObjCommit, gitObjTree, gitObjBlob, gitObjTag:
+		default:
+			return nil, fmt.Errorf("unsupported object type %d in pack", objType)
+		}
+
+		data, err := inflateGitObject(r, size, limit)
+		if err != nil {
+			return nil, err
+		}
+		limit -= len(data)
+		switch objType {
+		case gitObjOfsDelta:
+			if data, err = applyGitDelta(base.data, data, limit); err != nil {
+				return nil, err
+			}
+			limit -= len(data)
+			objType = base.objType
+		case gitObjRefDelta:
+			refDeltas = append(refDeltas, refDelta{baseName, data})
+			continue
+		}
+		obj := gitObject{data, objType}
+		byOffset[offset] = obj
+		objects[gitObjectName(obj)] = obj
+	}
+
+	// Bases of ref deltas may follow the delta, or be deltas themselves
+	for len(refDeltas) > 0 {
+		var pending []refDelta
+		for _, delta := range refDeltas {
+			base, found := objects[delta.base]
+			if !found {
+				pending = append(pending, delta)
+				continue
+			}
+			data, err := applyGitDelta(base.data, delta.delta, limit)
+			if err != nil {
+				return nil, err
+			}
+			limit -= len(data)
+			obj := gitObject{data, base.objType}
+			objects[gitObjectName(obj)] = obj
+		}
+		if len(pending) == len(refDeltas) {
+			return nil, fmt.Errorf("base %s of delta not in pack", pending[0].base)
+		}
+		refDeltas = pending
+	}
+	return objects, nil
+}
+
+// Type and size of an object in a pack. The size is encoded in the
+// low bits of the type byte, continued in bytes with the high bit set
+func readGitObjectHeader(r *bytes.Reader) (objType int, size uint64, err error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, errors.New("truncated pack")
+	}
+	objType = int(c>>4) & 7
+	size = uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil || shift > 57 {
+			return 0, 0, errors.New("malformed object header in pack")
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+	return objType, size, nil
+}
+
+// Distance back in the pack to the base of an offset delta
+func readGitDeltaDistance(r *bytes.Reader) (int, error) {
+	c, err := r.ReadByte()
+	distance := int(c & 0x7f)
+	for err == nil && c&0x80 != 0 {
+		if distance > maxGitPackSize {
+			return 0, errors.New("malformed delta in pack")
+		}
+		c, err = r.ReadByte()
+		distance = (distance+1)<<7 | int(c&0x7f)
+	}
+	return distance, err
+}
+
+// Inflate an object of a pack, leaving the reader at the next object.
+// The object must not be larger than limit bytes
+func inflateGitObject(r *bytes.Reader, size uint64, limit int) ([]byte, error) {
+	if size > uint64(max(limit, 0)) {
+		return nil, errGitObjectsTooLarge
+	}
+	// The bytes.Reader is an io.ByteReader, i.e. the decompressor
+	// does not read beyond the end of the object
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed object in pack: %w", err)
+	}
+	defer z.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(z, data); err != nil {
+		return nil, fmt.Errorf("malformed object in pack: %w", err)
+	}
+	if n, err := z.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		return nil, errors.New("object larger than its header in pack")
+	}
+	return data, nil
+}
+
+// Apply a delta to its base. Deltas hold the base and result sizes,
+// followed by instructions copying from the base or inserting data.
+// The result must not be larger than limit bytes
+func applyGitDelta(base, delta []byte, limit int) ([]byte, error) {
+	errMalformed := errors.New("malformed delta in pack")
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil || baseSize != uint64(len(base)) {
+		return nil, errMalformed
+	}
+	resultSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, errMalformed
+	}
+	if resultSize > uint64(max(limit, 0)) {
+		return nil, errGitObjectsTooLarge
+	}
+	result := make([]byte, 0, resultSize)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch {
+		case op&0x80 != 0:
+			var offset, size uint64
+			for bit := range 7 {
+				if op&(1<<bit) == 0 {
+					continue
+				}
+				c, err := r.ReadByte()
+				if err != nil {
+					return nil, errMalformed
+				}
+				if bit < 4 {
+					offset |= uint64(c) << (8 * bit)
+				} else {
+					size |= uint64(c) << (8 * (bit - 4))
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errMalformed
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > r.Len() {
+				return nil, errMalformed
+			}
+			start := len(delta) - r.Len()
+			result = append(result, delta[start:start+int(op)]...)
+			_, _ = r.Seek(int64(op), io.SeekCurrent)
+		default:
+			return nil, errMalformed
+		}
+		if uint64(len(result)) > resultSize {
+			return nil, errMalformed
+		}
+	}
+	if uint64(len(result)) != resultSize {
+		return nil, errMalformed
+	}
+	return result, nil
+}
+
+// Name of an object, i.e. the SHA-1 of its type, size and content
+func gitObjectName(obj gitObject) string {
+	h := sha1.New() //nolint:gosec // Git object names are SHA-1
+	fmt.Fprintf(h, "%s %d\x00", gitObjTypeNames[obj.objType], len(obj.data))
+	h.Write(obj.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
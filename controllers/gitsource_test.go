@@ -0,0 +1,324 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1" //nolint:gosec // Git object names are SHA-1
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Builds packs for tests. Objects are added as full objects or as
+// deltas against objects already added
+type testGitPack struct {
+	body    bytes.Buffer
+	offsets map[string]int
+	count   uint32
+}
+
+func (p *testGitPack) add(objType int, data []byte) string {
+	name := gitObjectName(gitObject{data, objType})
+	p.offsets[name] = p.body.Len()
+	p.writeObject(objType, data, nil)
+	return name
+}
+
+// Add an object as a delta copying the prefix shared with its base and
+// inserting the rest, using an offset or a ref delta
+func (p *testGitPack) addDelta(objType int, data, base []byte, ofs bool) string {
+	var delta bytes.Buffer
+	delta.Write(binary.AppendUvarint(nil, uint64(len(base))))
+	delta.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	n := 0
+	for n < len(base) && n < len(data) && base[n] == data[n] && n < 255 {
+		n++
+	}
+	if n > 0 {
+		delta.Write([]byte{0x80 | 0x10, byte(n)})
+	}
+	for rest := data[n:]; len(rest) > 0; {
+		chunk := rest[:min(len(rest), 127)]
+		delta.WriteByte(byte(len(chunk)))
+		delta.Write(chunk)
+		rest = rest[len(chunk):]
+	}
+
+	baseName := gitObjectName(gitObject{base, objType})
+	name := gitObjectName(gitObject{data, objType})
+	offset := p.body.Len()
+	p.offsets[name] = offset
+	if ofs {
+		distance := offset - p.offsets[baseName]
+		encoded := []byte{byte(distance & 0x7f)}
+		for distance >>= 7; distance > 0; distance >>= 7 {
+			distance--
+			encoded = append([]byte{byte(0x80 | distance&0x7f)}, encoded...)
+		}
+		p.writeObject(gitObjOfsDelta, delta.Bytes(), encoded)
+	} else {
+		id, _ := hex.DecodeString(baseName)
+		p.writeObject(gitObjRefDelta, delta.Bytes(), id)
+	}
+	return name
+}
+
+func (p *testGitPack) writeObject(objType int, data, baseRef []byte) {
+	size := len(data)
+	c := byte(objType<<4) | byte(size&0x0f)
+	for size >>= 4; size > 0; size >>= 7 {
+		p.body.WriteByte(c | 0x80)
+		c = byte(size & 0x7f)
+	}
+	p.body.WriteByte(c)
+	p.body.Write(baseRef)
+	z := zlib.NewWriter(&p.body)
+	_, _ = z.Write(data)
+	_ = z.Close()
+	p.count++
+}
+
+func (p *testGitPack) bytes() []byte {
+	pack := []byte("PACK")
+	pack = binary.BigEndian.AppendUint32(pack, 2)
+	pack = binary.BigEndian.AppendUint32(pack, p.count)
+	pack = append(pack, p.body.Bytes()...)
+	sum := sha1.Sum(pack) //nolint:gosec // Pack checksums are SHA-1
+	return append(pack, sum[:]...)
+}
+
+func testGitTree(entries ...string) []byte {
+	var tree []byte
+	for i := 0; i < len(entries); i += 3 {
+		id, _ := hex.DecodeString(entries[i+2])
+		tree = append(tree, entries[i]+" "+entries[i+1]+"\x00"...)
+		tree = append(tree, id...)
+	}
+	return tree
+}
+
+func TestGitBlueprintSource(t *testing.T) {
+	scheme := OfflineScheme()
+	r := &offlineClient{client: fake.NewClientBuilder().WithScheme(scheme).Build(), scheme: scheme}
+
+	savedCache := blueprintSourceCache.entries
+	blueprintSourceCache.entries = map[string]*blueprintSourceEntry{}
+	defer func() { blueprintSourceCache.entries = savedCache }()
+
+	readme := []byte("Blueprints shared by clusters\n\n" + strings.Repeat("Lorem ipsum. ", 20))
+	other := []byte("Blueprints shared by clusters, and more\n")
+	pack := &testGitPack{offsets: map[string]int{}}
+	readmeName := pack.add(gitObjBlob, readme)
+	blueprint := pack.addDelta(gitObjBlob, []byte(sourceBlueprint), readme, true)
+	otherName := gitObjectName(gitObject{other, gitObjBlob})
+	dir := pack.add(gitObjTree, testGitTree("100644", "other.yaml", otherName, "100644", "shared.yaml", blueprint))
+	root := pack.add(gitObjTree, testGitTree("100644", "README", readmeName, "40000", "blueprints", dir))
+	commit := pack.add(gitObjCommit, []byte("tree "+root+"\nauthor A <a@example.com> 0 +0000\n\nBlueprints\n"))
+	pack.addDelta(gitObjBlob, other, readme, false) // Ref delta after the tree referencing it
+	packData := pack.bytes()
+
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var resp bytes.Buffer
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/blueprints.git/info/refs":
+			writePktLine(&resp, "# service=git-upload-pack")
+			resp.WriteString("0000")
+			if req.Header.Get("Git-Protocol") == "version=2" {
+				writePktLine(&resp, "version 2")
+				writePktLine(&resp, "fetch=shallow")
+			}
+			resp.WriteString("0000")
+		case req.Method == http.MethodPost && req.URL.Path == "/blueprints.git/git-upload-pack":
+			requests++
+			body, _ := io.ReadAll(req.Body)
+			if !bytes.Contains(body, []byte("want "+commit+"\n")) || !bytes.Contains(body, []byte("deepen 1\n")) {
+				writePktLine(&resp, "ERR upload-pack: not our ref")
+				break
+			}
+			writePktLine(&resp, "shallow-info")
+			writePktLine(&resp, "shallow "+commit)
+			resp.WriteString("0001")
+			writePktLine(&resp, "packfile")
+			for rest := packData; len(rest) > 0; {
+				chunk := rest[:min(len(rest), 100)]
+				fmt.Fprintf(&resp, "%04x\x01%s", len(chunk)+5, chunk)
+				rest = rest[len(chunk):]
+			}
+			resp.WriteString("0000")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(resp.Bytes())
+	}))
+	defer srv.Close()
+	savedClient := gitHTTPClient
+	gitHTTPClient = srv.Client()
+	defer func() { gitHTTPClient = savedClient }()
+
+	source := &gwcapi.GitBlueprintSource{Repository: srv.URL + "/blueprints.git", Commit: commit, Path: "blueprints/shared.yaml"}
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "gwcb"}}
+	gwcb.Spec.Source = &gwcapi.BlueprintSource{Git: source}
+	for range 2 {
+		resolved, err := resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource)
+		if err != nil {
+			t.Fatalf("Cannot resolve blueprint: %v", err)
+		}
+		if resolved.Spec.GatewayTemplate.ResourceTemplates["lb"] != "source-lb" || resolved.Status.SourceRevision != commit {
+			t.Errorf("Got templates %v and revision %q, expected lb and commit",
+				resolved.Spec.GatewayTemplate.ResourceTemplates, resolved.Status.SourceRevision)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Got %d fetches, expected 1 since commit is cached", requests)
+	}
+
+	cases := []struct {
+		name     string
+		source   gwcapi.GitBlueprintSource
+		expected string
+	}{
+		{"other file", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit, Path: "blueprints/other.yaml"}, "cannot decode"},
+		{"missing file", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit, Path: "blueprints/missing.yaml"}, "not found"},
+		{"file as directory", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit, Path: "README/shared.yaml"}, "not a directory"},
+		{"directory as file", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit, Path: "blueprints"}, "not a regular file"},
+		{"unknown commit", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: root, Path: "blueprints/shared.yaml"}, "not our ref"},
+		{"short commit", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit[:7], Path: "blueprints/shared.yaml"}, "not a full SHA"},
+		{"http", gwcapi.GitBlueprintSource{Repository: "http" + strings.TrimPrefix(source.Repository, "https"), Commit: commit, Path: "blueprints/shared.yaml"}, "not an https URL"},
+		{"path outside repository", gwcapi.GitBlueprintSource{Repository: source.Repository, Commit: commit, Path: "../shared.yaml"}, "not a valid path"},
+	}
+	for _, tc := range cases {
+		_, _, err := readGitBlueprint(context.Background(), &tc.source)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%s: got error %v, expected %q", tc.name, err, tc.expected)
+		}
+	}
+
+	tampered := bytes.Clone(packData)
+	tampered[len(tampered)/2] ^= 0xff
+	if _, err := parseGitPack(tampered, maxGitObjectsSize); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Got error %v, expected checksum mismatch", err)
+	}
+
+	// Objects inflated, including those resolved from deltas, are limited in total
+	objects, err := parseGitPack(packData, maxGitObjectsSize)
+	if err != nil {
+		t.Fatalf("Cannot parse pack: %v", err)
+	}
+	total := 0
+	for _, obj := range objects {
+		total += len(obj.data)
+	}
+	if _, err := parseGitPack(packData, 2*total); err != nil { // Deltas are also inflated
+		t.Errorf("Got error %v parsing pack with limit above its size", err)
+	}
+	if _, err := parseGitPack(packData, total-1); !errors.Is(err, errGitObjectsTooLarge) {
+		t.Errorf("Got error %v, expected objects exceeding the limit", err)
+	}
+}
+
+// Fetch from a repository served by 'git http-backend', i.e. the
+// server of Git itself
+func TestGitBlueprintSourceHTTPBackend(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	repo := filepath.Join(root, "blueprints")
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(gitPath, args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+root,
+			"GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=a@example.com", "GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=a@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "blueprints"), 0o700); err != nil {
+		t.Fatalf("Cannot create repository: %v", err)
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "blueprints", "shared.yaml"), []byte(sourceBlueprint), 0o600); err != nil {
+		t.Fatalf("Cannot write blueprint: %v", err)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "Add blueprint")
+	commit := git("rev-parse", "HEAD")
+	// The commit fetched is not the tip of any branch
+	if err := os.WriteFile(filepath.Join(repo, "blueprints", "shared.yaml"), []byte(sourceBlueprint+"# Changed\n"), 0o600); err != nil {
+		t.Fatalf("Cannot write blueprint: %v", err)
+	}
+	git("commit", "-q", "-a", "-m", "Change blueprint")
+
+	srv := httptest.NewTLSServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Root: "/",
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1", "GIT_CONFIG_NOSYSTEM=1", "HOME=" + root},
+	})
+	defer srv.Close()
+	savedClient := gitHTTPClient
+	gitHTTPClient = srv.Client()
+	defer func() { gitHTTPClient = savedClient }()
+
+	spec, revision, err := readGitBlueprint(context.Background(),
+		&gwcapi.GitBlueprintSource{Repository: srv.URL + "/blueprints", Commit: commit, Path: "blueprints/shared.yaml"})
+	if err != nil {
+		t.Fatalf("Cannot read blueprint: %v", err)
+	}
+	if spec.GatewayTemplate.ResourceTemplates["lb"] != "source-lb" || revision != commit {
+		t.Errorf("Got templates %v and revision %q, expected lb and commit", spec.GatewayTemplate.ResourceTemplates, revision)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Maximum nesting of 'include' and 'tpl', guarding against templates including themselves
+const maxIncludeDepth = 100
+
+// Default maximum length of names from 'sanitizeName', i.e. the
+// length of DNS-1123 labels
+const defaultSanitizeNameLength = 63
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Functions available to templates in addition to the Sprig
+// functions. The 'include' and 'tpl' functions execute templates
+// associated with tmpl
+func helperFuncs(tmpl *template.Template) template.FuncMap {
+	depth := 0
+	include := func(name string, data any) (string, error) {
+		if depth >= maxIncludeDepth {
+			return "", fmt.Errorf("include of %q nested too deeply", name)
+		}
+		depth++
+		defer func() { depth-- }()
+		var buffer bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buffer, name, data); err != nil {
+			return "", err
+		}
+		return buffer.String(), nil
+	}
+	tpl := func(text string, data any) (string, error) {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return "", err
+		}
+		if _, err := clone.New("tpl").Parse(text); err != nil {
+			return "", fmt.Errorf("cannot parse tpl: %w", err)
+		}
+		if depth >= maxIncludeDepth {
+			return "", errors.New("tpl nested too deeply")
+		}
+		depth++
+		defer func() { depth-- }()
+		var buffer bytes.Buffer
+		if err := clone.ExecuteTemplate(&buffer, "tpl", data); err != nil {
+			return "", err
+		}
+		return buffer.String(), nil
+	}
+	return template.FuncMap{
+		"toYaml":            helperToYaml,
+		"fromYaml":          helperFromYaml,
+		"required":          helperRequired,
+		"hostnameMatches":   hostnamesIntersect,
+		"listenerFor":       helperListenerFor,
+		"routeRulesToPaths": helperRouteRulesToPaths,
+		"sanitizeName":      helperSanitizeName,
+		"include":           include,
+		"tpl":               tpl,
+		"lookup":            lookupUnavailable,
+	}
+}
+
+// This function is made available to templates as 'fromYaml'
+func helperFromYaml(s string) (map[string]any, error) {
+	m := map[string]any{}
+	if err := sigsyaml.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("cannot parse yaml: %w", err)
+	}
+	return m, nil
+}
+
+// This function is made available to templates as 'required'. It
+// fails rendering with the given message if the value is nil or an
+// empty string
+func helperRequired(msg string, v any) (any, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// This function is made available to templates as 'listenerFor'. It
+// returns the listeners of a Gateway a HTTPRoute is attached to, see
+// attachedListeners. The namespace of the HTTPRoute is needed for
+// listeners selecting namespaces by label, e.g.
+// 'listenerFor .Gateway .HTTPRoute .Namespace'
+func helperListenerFor(gwValue, rtValue any, nsValue ...any) ([]map[string]any, error) {
+	gwMap, err := templateMap(gwValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway: %w", err)
+	}
+	var gw gatewayapi.Gateway
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(gwMap, &gw); err != nil {
+		return nil, fmt.Errorf("invalid gateway: %w", err)
+	}
+	rtMap, err := templateMap(rtValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid httproute: %w", err)
+	}
+	var rt gatewayapi.HTTPRoute
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rtMap, &rt); err != nil {
+		return nil, fmt.Errorf("invalid httproute: %w", err)
+	}
+	var nsLabels labels.Set
+	if len(nsValue) > 0 {
+		nsMap, err := templateMap(nsValue[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace: %w", err)
+		}
+		nsLabels, _, _ = unstructured.NestedStringMap(nsMap, "metadata", "labels")
+	}
+
+	names := attachedListeners(&gw, &rt, nsLabels)
+	listeners, _, _ := unstructured.NestedSlice(gwMap, "spec", "listeners")
+	result := []map[string]any{}
+	for _, l := range listeners {
+		lMap, ok := l.(map[string]any)
+		if ok && slices.Contains(names, fmt.Sprint(lMap["name"])) {
+			result = append(result, lMap)
+		}
+	}
+	return result, nil
+}
+
+// This function is made available to templates as
+// 'routeRulesToPaths'. It returns the path matches of all rules of a
+// HTTPRoute as maps with the keys 'rule' (index of the rule), 'type'
+// and 'value'. Matches without a path, and rules without matches,
+// match all paths, i.e. 'PathPrefix' and '/'
+func helperRouteRulesToPaths(rtValue any) ([]map[string]any, error) {
+	rtMap, err := templateMap(rtValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid httproute: %w", err)
+	}
+	var rt gatewayapi.HTTPRoute
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rtMap, &rt); err != nil {
+		return nil, fmt.Errorf("invalid httproute: %w", err)
+	}
+	paths := []map[string]any{}
+	for ruleIdx, rule := range rt.Spec.Rules {
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayapi.HTTPRouteMatch{{}}
+		}
+		for _, match := range matches {
+			pathType, value := gatewayapi.PathMatchPathPrefix, "/"
+			if match.Path != nil {
+				if match.Path.Type != nil {
+					pathType = *match.Path.Type
+				}
+				if match.Path.Value != nil {
+					value = *match.Path.Value
+				}
+			}
+			paths = append(paths, map[string]any{"rule": ruleIdx, "type": string(pathType), "value": value})
+		}
+	}
+	return paths, nil
+}
+
+// This function is made available to templates as 'sanitizeName'. It
+// returns a DNS-1123 label from a name, i.e. lower case alphanumeric
+// characters or '-'. Names longer than the maximum length (63 unless
+// given) are truncated with a hash of the full name appended to keep
+// them unique
+func helperSanitizeName(name string, maxLen ...int) string {
+	length := defaultSanitizeNameLength
+	if len(maxLen) > 0 && maxLen[0] > 0 {
+		length = maxLen[0]
+	}
+	sanitized := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(sanitized) <= length {
+		return sanitized
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	if length <= len(hash) {
+		return hash[:length]
+	}
+	return strings.TrimRight(sanitized[:length-len(hash)-1], "-") + "-" + hash
+}
+
+// A map from the template values, e.g. '.HTTPRoute', or a pointer to
+// one, e.g. '.Gateway'
+func templateMap(v any) (map[string]any, error) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, nil
+	case *map[string]any:
+		if m != nil {
+			return *m, nil
+		}
+	}
+	return nil, fmt.Errorf("expected map, got %T", v)
+}
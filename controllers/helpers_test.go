@@ -0,0 +1,138 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+var helpersGateway = `
+metadata:
+  name: gw
+  namespace: foo
+spec:
+  gatewayClassName: test
+  listeners:
+  - name: http
+    port: 80
+    protocol: HTTP
+  - name: https
+    port: 443
+    protocol: HTTPS
+    hostname: "*.example.com"
+  - name: other
+    port: 443
+    protocol: HTTPS
+    hostname: "*.example.org"
+`
+
+var helpersRoute = `
+metadata:
+  name: rt
+  namespace: foo
+spec:
+  hostnames:
+  - www.example.com
+  parentRefs:
+  - name: gw
+  rules:
+  - matches:
+    - path:
+        type: Exact
+        value: /login
+    - headers:
+      - name: x-test
+        value: "1"
+  - {}
+`
+
+func TestTemplateHelpers(t *testing.T) {
+	var gw, rt map[string]any
+	if err := sigsyaml.Unmarshal([]byte(helpersGateway), &gw); err != nil {
+		t.Fatalf("Cannot parse gateway: %v", err)
+	}
+	if err := sigsyaml.Unmarshal([]byte(helpersRoute), &rt); err != nil {
+		t.Fatalf("Cannot parse httproute: %v", err)
+	}
+	values := &TemplateValues{
+		Gateway:   &gw,
+		HTTPRoute: rt,
+		Values:    map[string]any{"name": "Foo_Bar.example", "empty": "", "yaml": "a: 1\nb: [x, z]"},
+	}
+
+	for _, tc := range []struct {
+		template, expected string
+	}{
+		{`{{ (fromYaml .Values.yaml).b | join "," }}`, "x,z"},
+		{`{{ toJson (fromYaml .Values.yaml) }}`, `{"a":1,"b":["x","z"]}`},
+		{`{{ required "name required" .Values.name }}`, "Foo_Bar.example"},
+		{`{{ hostnameMatches "*.example.com" "www.example.com" }} {{ hostnameMatches "a.com" "b.com" }}`, "true false"},
+		{`{{ range listenerFor .Gateway .HTTPRoute }}{{ .name }},{{ end }}`, "http,https,"},
+		{`{{ range routeRulesToPaths .HTTPRoute }}{{ .rule }}:{{ .type }}:{{ .value }} {{ end }}`,
+			"0:Exact:/login 0:PathPrefix:/ 1:PathPrefix:/ "},
+		{`{{ sanitizeName .Values.name }}`, "foo-bar-example"},
+		{`{{ sanitizeName (repeat 70 "a") | len }} {{ sanitizeName "Foo-Bar-Baz-Qux" 12 }}`, "63 foo-91e9cce5"},
+		{`{{ define "labels" }}name: {{ .name }}{{ end }}{{ include "labels" .Values | upper }}`, "NAME: FOO_BAR.EXAMPLE"},
+		{`{{ tpl "{{ .Values.name }}-suffix" . }}`, "Foo_Bar.example-suffix"},
+	} {
+		tmpl, err := parseSingleTemplate("test", "", tc.template)
+		if err != nil {
+			t.Fatalf("Cannot parse template %q: %v", tc.template, err)
+		}
+		buffer, err := templateRender(tmpl, values)
+		if err != nil {
+			t.Fatalf("Cannot render template %q: %v", tc.template, err)
+		}
+		if buffer.String() != tc.expected {
+			t.Errorf("Template %q: got %q, expected %q", tc.template, buffer.String(), tc.expected)
+		}
+	}
+
+	for _, tmplText := range []string{
+		`{{ required "empty required" .Values.empty }}`,
+		`{{ fromYaml "a: [" }}`,
+		`{{ define "loop" }}{{ include "loop" . }}{{ end }}{{ include "loop" . }}`,
+	} {
+		tmpl, err := parseSingleTemplate("test", "", tmplText)
+		if err != nil {
+			t.Fatalf("Cannot parse template %q: %v", tmplText, err)
+		}
+		if _, err := templateRender(tmpl, values); err == nil {
+			t.Errorf("Template %q: expected error", tmplText)
+		} else if strings.Contains(tmplText, "required") && !strings.Contains(err.Error(), "empty required") {
+			t.Errorf("Template %q: got error %v, expected message from template", tmplText, err)
+		}
+	}
+}
@@ -33,19 +33,31 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
 )
 
@@ -53,11 +65,18 @@ type HTTPRouteReconciler struct {
 	client    client.Client
 	scheme    *runtime.Scheme
 	dynClient dynamic.Interface
+	recorder  record.EventRecorder
+
+	// Objects read by templates using 'lookup'
+	lookups *lookupWatcher
 }
 
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/finalizers,verbs=update
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
 
 func (r *HTTPRouteReconciler) Client() client.Client {
 	return r.client
@@ -71,19 +90,202 @@ func (r *HTTPRouteReconciler) DynamicClient() dynamic.Interface {
 	return r.dynClient
 }
 
+func (r *HTTPRouteReconciler) Recorder() record.EventRecorder {
+	return r.recorder
+}
+
 func NewHTTPRouteController(mgr ctrl.Manager, config *rest.Config) *HTTPRouteReconciler {
 	r := &HTTPRouteReconciler{
 		client:    mgr.GetClient(),
 		scheme:    mgr.GetScheme(),
 		dynClient: dynamic.NewForConfigOrDie(config),
+		recorder:  mgr.GetEventRecorderFor(eventSourceName),
 	}
 	return r
 }
 
 func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&gatewayapi.HTTPRoute{}).
-		Complete(r)
+	// Policies may select Namespaces, GatewayClasses and Gateways
+	// by label, hence label changes trigger reconciles. Namespaces
+	// and GatewayClasses are also available to templates, including
+	// their annotations. Policies may also reference
+	// values in ConfigMaps and Secrets. Backend Services are
+	// available to templates, subject to ReferenceGrants. Templates
+	// are rendered again when a blueprint or its bases change,
+	// including the revision read from their sources
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayapi.HTTPRoute{}, builder.WithPredicates(inventoryChangePredicate)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesInNamespace),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&gatewayapi.Gateway{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesOfGateway),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&gatewayapi.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesOfGatewayClass),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesOfBlueprint),
+			builder.WithPredicates(blueprintChangedPredicate)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesUsingValuesFrom("ConfigMap")),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesUsingValuesFrom("Secret")),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesWithBackendService),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&gatewayapiv1b1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(r.httpRoutesWithBackendInNamespace),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.lookups = newLookupWatcher(mgr.GetCache(), c)
+	return nil
+}
+
+// Map a Namespace to the HTTPRoutes in the namespace or with a parent Gateway in the namespace
+func (r *HTTPRouteReconciler) httpRoutesInNamespace(ctx context.Context, ns client.Object) []reconcile.Request {
+	return r.httpRoutesWithParent(ctx, func(rt *gatewayapi.HTTPRoute, parent types.NamespacedName) bool {
+		return rt.Namespace == ns.GetName() || parent.Namespace == ns.GetName()
+	})
+}
+
+// Map a Gateway to the HTTPRoutes attached to it
+func (r *HTTPRouteReconciler) httpRoutesOfGateway(ctx context.Context, gw client.Object) []reconcile.Request {
+	return r.httpRoutesWithParent(ctx, func(_ *gatewayapi.HTTPRoute, parent types.NamespacedName) bool {
+		return parent == client.ObjectKeyFromObject(gw)
+	})
+}
+
+// Map a GatewayClass to all HTTPRoutes with a Gateway parent. Label
+// changes on GatewayClasses are rare, hence parent Gateways are not
+// looked up to find the class
+func (r *HTTPRouteReconciler) httpRoutesOfGatewayClass(ctx context.Context, _ client.Object) []reconcile.Request {
+	return r.httpRoutesWithParent(ctx, func(*gatewayapi.HTTPRoute, types.NamespacedName) bool {
+		return true
+	})
+}
+
+// Map a GatewayClassBlueprint to the HTTPRoutes with a parent Gateway
+// of a GatewayClass using it, see gatewayClassesUsingBlueprint
+func (r *HTTPRouteReconciler) httpRoutesOfBlueprint(ctx context.Context, gwcb client.Object) []reconcile.Request {
+	classes, err := gatewayClassesUsingBlueprint(ctx, r, gwcb.GetName())
+	if err != nil {
+		log.FromContext(ctx).Error(err, "cannot lookup GatewayClasses of blueprint", "blueprint", gwcb.GetName())
+		return nil
+	}
+	if len(classes) == 0 {
+		return nil
+	}
+	var gwList gatewayapi.GatewayList
+	if err := r.Client().List(ctx, &gwList); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list Gateways")
+		return nil
+	}
+	gateways := map[types.NamespacedName]bool{}
+	for idx := range gwList.Items {
+		gw := &gwList.Items[idx]
+		for _, gwc := range classes {
+			if string(gw.Spec.GatewayClassName) == gwc.Name {
+				gateways[client.ObjectKeyFromObject(gw)] = true
+			}
+		}
+	}
+	return r.httpRoutesWithParent(ctx, func(_ *gatewayapi.HTTPRoute, parent types.NamespacedName) bool {
+		return gateways[parent]
+	})
+}
+
+// Map a ConfigMap or Secret referenced by policies or
+// GatewayClassBlueprints to the HTTPRoutes which may use its
+// values. References from the controller namespace may affect all
+// HTTPRoutes, otherwise only HTTPRoutes in the namespace or with a
+// parent Gateway in the namespace
+func (r *HTTPRouteReconciler) httpRoutesUsingValuesFrom(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		referenced, err := isValuesFromSource(ctx, r, kind, obj)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "cannot lookup references", "kind", kind, "object", objectRef(obj))
+			return nil
+		}
+		if !referenced {
+			return nil
+		}
+		return r.httpRoutesWithParent(ctx, func(rt *gatewayapi.HTTPRoute, parent types.NamespacedName) bool {
+			return obj.GetNamespace() == ControllerNamespace || rt.Namespace == obj.GetNamespace() ||
+				parent.Namespace == obj.GetNamespace()
+		})
+	}
+}
+
+// Map a Service to the HTTPRoutes with a backendRef to it
+func (r *HTTPRouteReconciler) httpRoutesWithBackendService(ctx context.Context, svc client.Object) []reconcile.Request {
+	return r.httpRoutesWithBackend(ctx, func(_ *gatewayapi.HTTPRoute, backend types.NamespacedName) bool {
+		return backend == client.ObjectKeyFromObject(svc)
+	})
+}
+
+// Map a ReferenceGrant to the HTTPRoutes with a backendRef to a
+// Service in its namespace from another namespace
+func (r *HTTPRouteReconciler) httpRoutesWithBackendInNamespace(ctx context.Context, grant client.Object) []reconcile.Request {
+	return r.httpRoutesWithBackend(ctx, func(rt *gatewayapi.HTTPRoute, backend types.NamespacedName) bool {
+		return backend.Namespace == grant.GetNamespace() && rt.Namespace != grant.GetNamespace()
+	})
+}
+
+// HTTPRoutes with a backendRef to a Service for which match returns true
+func (r *HTTPRouteReconciler) httpRoutesWithBackend(ctx context.Context,
+	match func(*gatewayapi.HTTPRoute, types.NamespacedName) bool) []reconcile.Request {
+	var rtList gatewayapi.HTTPRouteList
+	if err := r.Client().List(ctx, &rtList); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list HTTPRoutes")
+		return nil
+	}
+	var requests []reconcile.Request
+	for idx := range rtList.Items {
+		rt := &rtList.Items[idx]
+	rules:
+		for _, rule := range rt.Spec.Rules {
+			for _, bref := range rule.BackendRefs {
+				if !isServiceBackendRef(&bref.BackendObjectReference) {
+					continue
+				}
+				backend := types.NamespacedName{Namespace: rt.Namespace, Name: string(bref.Name)}
+				if bref.Namespace != nil {
+					backend.Namespace = string(*bref.Namespace)
+				}
+				if match(rt, backend) {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(rt)})
+					break rules
+				}
+			}
+		}
+	}
+	return requests
+}
+
+// HTTPRoutes with a parent Gateway for which match returns true
+func (r *HTTPRouteReconciler) httpRoutesWithParent(ctx context.Context,
+	match func(*gatewayapi.HTTPRoute, types.NamespacedName) bool) []reconcile.Request {
+	var rtList gatewayapi.HTTPRouteList
+	if err := r.Client().List(ctx, &rtList); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list HTTPRoutes")
+		return nil
+	}
+	var requests []reconcile.Request
+	for idx := range rtList.Items {
+		rt := &rtList.Items[idx]
+		for _, pref := range rt.Spec.ParentRefs {
+			if pref.Kind != nil && *pref.Kind != "Gateway" {
+				continue
+			}
+			parent := types.NamespacedName{Namespace: rt.Namespace, Name: string(pref.Name)}
+			if pref.Namespace != nil {
+				parent.Namespace = string(*pref.Namespace)
+			}
+			if match(rt, parent) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(rt)})
+				break
+			}
+		}
+	}
+	return requests
 }
 
 // Compare values referenced by pointers. Both a and b must be pointers to the same type
@@ -147,25 +349,50 @@ func setRouteStatusCondition(rtStatus *gatewayapi.RouteStatus, parent gatewayapi
 }
 
 func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "reconcileHTTPRoute", trace.WithAttributes(attrNamespace.String(req.Namespace), attrName.String(req.Name)))
+	defer span.End()
+
+	result, err := r.reconcile(ctx, req)
+	spanError(span, err)
+	return result, err
+}
+
+func (r *HTTPRouteReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	var doStatusUpdate = false
 	var requeue = false
+	var inventory = []InventoryEntry{}
+	var children = httpRouteMetricsState{}
+	var errStatus error // Errors applying templates, reported after status and inventory updates
+	var lookupDeps []lookupDependency
+	var policies []*attachedPolicy // Policies attached through any parent
 	var rt gatewayapi.HTTPRoute
 	if err := r.Client().Get(ctx, req.NamespacedName, &rt); err != nil {
+		if apierrors.IsNotFound(err) {
+			deleteHTTPRouteMetrics(req.NamespacedName)
+			forgetPolicyTarget(policyTarget{"HTTPRoute", req.NamespacedName})
+			_ = r.lookups.track(req.NamespacedName, nil)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	logger.Info("HTTPRoute")
 
 	// Prepare HTTPRoute resource for use in templates by converting to map[string]any
-	rtMap, err := objectToMap(&rt)
+	rtMap, err := parentToMap(&rt)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot convert httproute to map: %w", err)
 	}
 
+	nsMap, err := lookupNamespaceMap(ctx, r, rt.Namespace)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot lookup namespace: %w", err)
+	}
+
 	templateValues := TemplateValues{
 		HTTPRoute: rtMap,
+		Namespace: nsMap,
 	}
 
 	// Prepare for setting status in parentRef loop
@@ -182,6 +409,8 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		gw, err := lookupParent(ctx, r, &rt, parent)
 		if err != nil {
 			logger.Info("gateway for httproute not found", "httproute", rt.Name, "parent", parent)
+			r.recorder.Eventf(&rt, corev1.EventTypeWarning, EventReasonDependencyMissing,
+				"parent Gateway %q not found", parent.Name)
 			requeue = true
 			continue
 		}
@@ -194,6 +423,8 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
 		if err != nil {
 			logger.Info("gatewayClass not found", "gatewayclassname", gw.Spec.GatewayClassName)
+			r.recorder.Eventf(&rt, corev1.EventTypeWarning, EventReasonDependencyMissing,
+				"GatewayClass %q of parent Gateway %q not found", gw.Spec.GatewayClassName, gw.Name)
 			requeue = true
 			continue
 		}
@@ -201,27 +432,60 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			continue
 		}
 
-		gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc)
+		gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc, cachedBlueprintSource)
 		if err != nil {
 			logger.Info("parameters for GatewayClass not found", "gatewayclassparameters", gwc.Name)
+			r.recorder.Eventf(&rt, corev1.EventTypeWarning, EventReasonDependencyMissing,
+				"parameters for GatewayClass %q not found: %v", gwc.Name, err)
 			requeue = true
 			continue
 		}
 
-		values, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name)
+		lookup, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name, &req.NamespacedName)
+		var missing *missingValuesReferenceError
+		if errors.As(err, &missing) {
+			r.recorder.Event(&rt, corev1.EventTypeWarning, EventReasonDependencyMissing, missing.Error())
+			requeue = true
+			continue
+		}
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("cannot lookup values: %w", err)
 		}
-		templateValues.Values = values
+		for _, conflict := range lookup.conflicts {
+			r.recorder.Event(&rt, corev1.EventTypeWarning, EventReasonValueConflict, conflict)
+		}
+		policies = append(policies, lookup.policies...)
+		templateValues.Values = lookup.values
+		templateValues.sensitivePaths = lookup.sensitivePaths
+		templateValues.lookup = newTemplateLookup(ctx, r, gwcb)
+
+		backends, backendDeps, err := resolveBackends(ctx, r, &rt, gwcb.Spec.BackendEndpointSlices)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot resolve backends: %w", err)
+		}
+		templateValues.Backends = backends
+		lookupDeps = append(lookupDeps, backendDeps...)
 
 		// Prepare Gateway resource for use in templates by converting to map[string]any
-		gatewayMap, err := objectToMap(gw)
+		gatewayMap, err := parentToMap(gw)
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("cannot convert gateway to map: %w", err)
 		}
 		templateValues.Gateway = &gatewayMap
+		if templateValues.GatewayNamespace, err = lookupNamespaceMap(ctx, r, gw.Namespace); err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot lookup namespace of gateway: %w", err)
+		}
+		if templateValues.GatewayClass, err = objectToMap(gwc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot convert gatewayclass to map: %w", err)
+		}
+		if templateValues.Blueprint, err = blueprintToMap(gwcb); err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot convert gatewayclassblueprint to map: %w", err)
+		}
 
-		templates, err := parseTemplates(gwcb.Spec.HTTPRouteTemplate.ResourceTemplates)
+		_, parseSpan := tracer.Start(ctx, "parseTemplates", trace.WithAttributes(attrGatewayClass.String(gwc.Name)))
+		templates, err := parseTemplates(gwc.Name, gwcb.Spec.Helpers, gwcb.Spec.HTTPRouteTemplate.ResourceTemplates)
+		spanError(parseSpan, err)
+		parseSpan.End()
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -234,20 +498,28 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		for attempt := 0; attempt < len(templates); attempt++ {
 			logger.Info("start reconcile loop", "attempt", attempt)
 			isFinalAttempt := attempt == len(templates)-1
+			attemptCtx, attemptSpan := tracer.Start(ctx, "renderAttempt", trace.WithAttributes(attrAttempt.Int(attempt)))
 
 			templateValues.Resources = buildResourceValues(templates)
 
-			renderedNum, existsNum = renderTemplates(ctx, r, &rt, templates, &templateValues, isFinalAttempt)
+			renderedNum, existsNum = renderTemplates(attemptCtx, r, &rt, templates, &templateValues, isFinalAttempt)
 			logger.Info("Rendered", "rendered", renderedNum, "exists", existsNum)
 
-			if err := applyTemplates(ctx, r, &rt, templates); err != nil {
-				return ctrl.Result{}, fmt.Errorf("unable to apply templates: %w", err)
+			if err := applyTemplates(attemptCtx, r, &rt, templates, false); err != nil {
+				errStatus = fmt.Errorf("unable to apply templates: %w", err)
+				spanError(attemptSpan, err)
 			}
+			attemptSpan.End()
 		}
+		recordApplyErrors(r.recorder, &rt, templates)
 		// If we haven't already decided to requeue, then requeue if not all templates could render (possibly a missing dependency)
 		requeue = requeue || (renderedNum != len(templates))
 		logger.Info("ending reconcile loop", "renderedNum", renderedNum, "totalNum", len(templates), "requeue", requeue)
 
+		inventory = append(inventory, buildInventory(templates, rt.Namespace, gw.Namespace+"/"+gw.Name)...)
+		lookupDeps = append(lookupDeps, templateValues.lookup.deps...)
+		children[gwc.Name] += childResourceCount(templates)
+
 		// FIXME errors in templating and status of sub-resources in general should set status conditions
 
 		// Update status for current parent Gateway
@@ -258,18 +530,54 @@ func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 				Status: "True",
 				Reason: string(gatewayapi.RouteReasonAccepted),
 			})
+		setRouteStatusCondition(&rt.Status.RouteStatus, parent, backendsCondition(backends))
+	}
+
+	if err := r.lookups.track(req.NamespacedName, lookupDeps); err != nil {
+		logger.Error(err, "unable to watch objects read by templates")
+	}
+
+	if err := updatePolicyStatus(ctx, r, policyTarget{"HTTPRoute", req.NamespacedName}, policies); err != nil {
+		logger.Error(err, "unable to update policy status")
 	}
 
 	if doStatusUpdate {
-		if err := r.Client().Status().Update(ctx, &rt); err != nil {
+		statusCtx, statusSpan := tracer.Start(ctx, "updateStatus")
+		err := r.Client().Status().Update(statusCtx, &rt)
+		spanError(statusSpan, err)
+		statusSpan.End()
+		if err != nil {
 			logger.Error(err, "unable to update HTTPRoute status")
 			return ctrl.Result{}, err
 		}
 	}
 
-	if requeue {
+	if len(children) > 0 {
+		setHTTPRouteMetrics(req.NamespacedName, children)
+	} else {
+		deleteHTTPRouteMetrics(req.NamespacedName)
+	}
+
+	if doStatusUpdate {
+		// Readiness is not part of the HTTPRoute status, hence
+		// transitions are found from the stored inventory
+		previous, found := storedInventory(&rt)
+		wasReady := found && inventoryIsReady(previous)
+		if err := updateInventory(ctx, r, &rt, inventory); err != nil {
+			logger.Error(err, "unable to update HTTPRoute inventory")
+			return ctrl.Result{}, err
+		}
+		isReady := inventoryIsReady(inventory) && !requeue && errStatus == nil
+		if isReady && !wasReady {
+			r.recorder.Event(&rt, corev1.EventTypeNormal, EventReasonReady, "HTTPRoute is ready")
+		} else if !isReady && wasReady {
+			r.recorder.Event(&rt, corev1.EventTypeWarning, EventReasonNotReady, "HTTPRoute is no longer ready")
+		}
+	}
+
+	if requeue && errStatus == nil {
 		logger.Info("requeue - not all resources updated")
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
 	}
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, errStatus
 }
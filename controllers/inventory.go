@@ -0,0 +1,210 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// Inventory information about a single child resource. The
+// inventory is stored as JSON in an annotation on the parent resource
+// such that e.g. `kubectl describe` shows what implements the parent
+type InventoryEntry struct {
+	// Name of template (from template key in GatewayClassBlueprint)
+	Template string `json:"template"`
+
+	// Parent Gateway as namespace/name. Only set for HTTPRoute
+	// inventories since a HTTPRoute may have multiple parents
+	Parent string `json:"parent,omitempty"`
+
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+
+	// Status as computed by kstatus, e.g. 'Current' or 'InProgress'
+	Status string `json:"status"`
+
+	// Latest render or apply error
+	Error string `json:"error,omitempty"`
+}
+
+// Build inventory from template states. Namespaced resources are
+// always created in the namespace of the parent resource, which is
+// given by 'ns'. Templates which could not be rendered are included
+// with an unknown status and the render error.
+func buildInventory(templates []*ResourceTemplateState, ns, parent string) []InventoryEntry {
+	inventory := make([]InventoryEntry, 0, len(templates))
+	for _, tmpl := range templates {
+		if len(tmpl.Resources) == 0 {
+			entry := InventoryEntry{
+				Template: tmpl.TemplateName,
+				Parent:   parent,
+				Status:   status.UnknownStatus.String(),
+			}
+			if tmpl.RenderErr != nil {
+				entry.Error = tmpl.RenderErr.Error()
+			}
+			inventory = append(inventory, entry)
+			continue
+		}
+		for _, res := range tmpl.Resources {
+			entry := InventoryEntry{
+				Template:   tmpl.TemplateName,
+				Parent:     parent,
+				APIVersion: res.Rendered.GetAPIVersion(),
+				Kind:       res.Rendered.GetKind(),
+				Name:       res.Rendered.GetName(),
+				Status:     status.NotFoundStatus.String(),
+			}
+			if res.IsNamespaced {
+				entry.Namespace = ns
+			}
+			if res.Current != nil {
+				if result, err := status.Compute(res.Current); err != nil {
+					entry.Status = status.UnknownStatus.String()
+					entry.Error = err.Error()
+				} else {
+					entry.Status = result.Status.String()
+				}
+			}
+			if res.ApplyErr != nil {
+				entry.Error = res.ApplyErr.Error()
+			}
+			inventory = append(inventory, entry)
+		}
+	}
+	return inventory
+}
+
+// Inventory stored in annotation on parent resource. Returns false if
+// the parent has no valid inventory
+func storedInventory(parent client.Object) ([]InventoryEntry, bool) {
+	data, found := parent.GetAnnotations()[selfapi.InventoryAnnotation]
+	if !found {
+		return nil, false
+	}
+	var inventory []InventoryEntry
+	if err := json.Unmarshal([]byte(data), &inventory); err != nil {
+		return nil, false
+	}
+	return inventory, true
+}
+
+// Whether all child resources of an inventory are current and without
+// errors
+func inventoryIsReady(inventory []InventoryEntry) bool {
+	for idx := range inventory {
+		if inventory[idx].Status != status.CurrentStatus.String() || inventory[idx].Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Store inventory in annotation on parent resource. The parent is only
+// patched if the inventory changed
+func updateInventory(ctx context.Context, r ControllerClient, parent client.Object, inventory []InventoryEntry) error {
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return err
+	}
+
+	annotations := parent.GetAnnotations()
+	if annotations[selfapi.InventoryAnnotation] == string(data) {
+		return nil
+	}
+
+	base, ok := parent.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("cannot copy %s", parent.GetName())
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[selfapi.InventoryAnnotation] = string(data)
+	parent.SetAnnotations(annotations)
+	return r.Client().Patch(ctx, parent, client.MergeFrom(base))
+}
+
+// Convert a parent resource to a map for use in templates. The
+// inventory annotation is left out since it follows the status of
+// child resources, i.e. templates copying the annotations of the
+// parent to children would change the inventory on every reconcile
+func parentToMap(parent client.Object) (map[string]any, error) {
+	mapObj, err := objectToMap(parent)
+	if err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(mapObj, "metadata", "annotations", selfapi.InventoryAnnotation)
+	return mapObj, nil
+}
+
+// Ignore updates of parent resources only changing the inventory,
+// i.e. the patches of updateInventory do not trigger reconciles
+var inventoryChangePredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !isInventoryOnlyChange(e.ObjectOld, e.ObjectNew)
+	},
+}
+
+func isInventoryOnlyChange(oldObj, newObj client.Object) bool {
+	if oldObj == nil || newObj == nil ||
+		oldObj.GetAnnotations()[selfapi.InventoryAnnotation] == newObj.GetAnnotations()[selfapi.InventoryAnnotation] {
+		return false
+	}
+	withoutInventory := func(obj client.Object) client.Object {
+		out, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			return obj
+		}
+		annotations := maps.Clone(out.GetAnnotations())
+		delete(annotations, selfapi.InventoryAnnotation)
+		out.SetAnnotations(annotations)
+		out.SetResourceVersion("")
+		out.SetManagedFields(nil)
+		return out
+	}
+	return equality.Semantic.DeepEqual(withoutInventory(oldObj), withoutInventory(newObj))
+}
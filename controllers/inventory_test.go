@@ -0,0 +1,157 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+func TestBuildInventory(t *testing.T) {
+	cm := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name": "foo",
+		},
+	}}
+	templates := []*ResourceTemplateState{
+		{
+			TemplateName: "configMap",
+			Resources: []ResourceComposite{{
+				Rendered:     cm,
+				GVR:          &schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				Current:      cm,
+				IsNamespaced: true,
+			}},
+		},
+		{
+			TemplateName: "applyFailed",
+			Resources: []ResourceComposite{{
+				Rendered:     cm,
+				GVR:          &schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				IsNamespaced: true,
+				ApplyErr:     errors.New("apply failed"),
+			}},
+		},
+		{
+			TemplateName: "renderFailed",
+			RenderErr:    errors.New("render failed"),
+		},
+	}
+
+	inventory := buildInventory(templates, "default", "default/gw")
+	if len(inventory) != 3 {
+		t.Fatalf("Inventory length mismatch, got %v, expected 3", len(inventory))
+	}
+	if inventory[0].Kind != "ConfigMap" || inventory[0].Namespace != "default" || inventory[0].Name != "foo" ||
+		inventory[0].Parent != "default/gw" || inventory[0].Status != "Current" || inventory[0].Error != "" {
+		t.Fatalf("Inventory[0] mismatch, got %+v", inventory[0])
+	}
+	if inventory[1].Status != "NotFound" || inventory[1].Error != "apply failed" {
+		t.Fatalf("Inventory[1] mismatch, got %+v", inventory[1])
+	}
+	if inventory[2].Template != "renderFailed" || inventory[2].Status != "Unknown" || inventory[2].Error != "render failed" {
+		t.Fatalf("Inventory[2] mismatch, got %+v", inventory[2])
+	}
+}
+
+func TestInventoryIsReady(t *testing.T) {
+	gw := &gatewayapi.Gateway{}
+	if _, found := storedInventory(gw); found {
+		t.Fatalf("Found inventory on Gateway without annotation")
+	}
+	gw.SetAnnotations(map[string]string{selfapi.InventoryAnnotation: `[{"template":"a","status":"Current"},{"template":"b","status":"InProgress"}]`})
+	inventory, found := storedInventory(gw)
+	if !found || len(inventory) != 2 {
+		t.Fatalf("Stored inventory mismatch, got %+v", inventory)
+	}
+	if inventoryIsReady(inventory) {
+		t.Fatalf("Inventory with resource in progress is ready")
+	}
+	inventory[1].Status = "Current"
+	if !inventoryIsReady(inventory) {
+		t.Fatalf("Inventory with current resources is not ready")
+	}
+	inventory[0].Error = "cannot apply"
+	if inventoryIsReady(inventory) {
+		t.Fatalf("Inventory with apply error is ready")
+	}
+}
+
+func TestParentInventory(t *testing.T) {
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "gw", ResourceVersion: "1",
+		Annotations: map[string]string{"team": "a", selfapi.InventoryAnnotation: `[]`}}}
+	gwMap, err := parentToMap(gw)
+	if err != nil {
+		t.Fatalf("Cannot convert Gateway: %v", err)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(gwMap, "metadata", "annotations")
+	if _, found := annotations[selfapi.InventoryAnnotation]; found || annotations["team"] != "a" {
+		t.Errorf("Got annotations %v, expected inventory left out", annotations)
+	}
+	if gw.Annotations[selfapi.InventoryAnnotation] != `[]` {
+		t.Errorf("Gateway modified")
+	}
+
+	inventoryChanged := gw.DeepCopy()
+	inventoryChanged.ResourceVersion = "2"
+	inventoryChanged.Annotations[selfapi.InventoryAnnotation] = `[{"template":"svc"}]`
+	if !isInventoryOnlyChange(gw, inventoryChanged) {
+		t.Errorf("Inventory patch not detected as inventory-only change")
+	}
+	inventoryAdded := gw.DeepCopy()
+	delete(inventoryAdded.Annotations, selfapi.InventoryAnnotation)
+	if !isInventoryOnlyChange(inventoryAdded, gw) {
+		t.Errorf("Added inventory not detected as inventory-only change")
+	}
+	annotationChanged := inventoryChanged.DeepCopy()
+	annotationChanged.Annotations["team"] = "b"
+	if isInventoryOnlyChange(gw, annotationChanged) {
+		t.Errorf("Annotation change detected as inventory-only change")
+	}
+	specChanged := inventoryChanged.DeepCopy()
+	specChanged.Spec.GatewayClassName = "other"
+	if isInventoryOnlyChange(gw, specChanged) {
+		t.Errorf("Spec change detected as inventory-only change")
+	}
+	if isInventoryOnlyChange(gw, gw.DeepCopy()) {
+		t.Errorf("Update without inventory change detected as inventory-only change")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+var (
+	// Dot-separated paths of template values which are redacted
+	// before logging, e.g. 'aws.secretKey'
+	RedactedValuePaths []string
+)
+
+// Verbosity of template debug logging, i.e. enabled with '--zap-log-level=debug'
+const debugLogLevel = 1
+
+// Text replacing redacted values
+const redactedValue = "<redacted>"
+
+// Logger for debugging templates, e.g. rendered resources and template
+// values. Debug information is logged with debug verbosity unless
+// enabled for the parent resource with the debug annotation.
+func debugLogger(ctx context.Context, parent metav1.Object) logr.Logger {
+	logger := log.FromContext(ctx)
+	if parent.GetAnnotations()[selfapi.DebugAnnotation] == "true" {
+		return logger
+	}
+	return logger.V(debugLogLevel)
+}
+
+// Return template values with redacted values, suitable for logging.
+// Current resources are reduced to their kind and name, since they
+// may hold sensitive data, e.g. Secrets rendered from values from
+// Secrets. The original values are not modified
+func redactTemplateValues(values *TemplateValues) TemplateValues {
+	redacted := *values
+	redacted.Values = redactValues(values.Values, values.redactedPaths())
+	redacted.Resources = redactResources(values.Resources)
+	return redacted
+}
+
+// Reduce current resources, see buildResourceValues, to their kind
+// and name
+func redactResources(resources map[string]any) map[string]any {
+	if resources == nil {
+		return nil
+	}
+	out := make(map[string]any, len(resources))
+	for tmplName, val := range resources {
+		current, _ := val.([]map[string]any)
+		names := make([]map[string]any, 0, len(current))
+		for _, res := range current {
+			obj := unstructured.Unstructured{Object: res}
+			names = append(names, map[string]any{"kind": obj.GetKind(), "name": obj.GetName()})
+		}
+		out[tmplName] = names
+	}
+	return out
+}
+
+// Paths of values to redact, i.e. RedactedValuePaths and the paths of
+// values from Secrets
+func (v *TemplateValues) redactedPaths() []string {
+	return append(slices.Clone(RedactedValuePaths), v.sensitivePaths...)
+}
+
+// Replace values at 'paths' with a placeholder. Maps along the paths
+// are copied, i.e. 'values' is not modified
+func redactValues(values map[string]any, paths []string) map[string]any {
+	for _, path := range paths {
+		values = redactPath(values, strings.Split(path, "."))
+	}
+	return values
+}
+
+func redactPath(values map[string]any, keys []string) map[string]any {
+	val, found := values[keys[0]]
+	if !found {
+		return values
+	}
+	var sub map[string]any
+	if len(keys) > 1 {
+		var ok bool
+		if sub, ok = val.(map[string]any); !ok {
+			return values // Path does not exist
+		}
+	}
+	out := make(map[string]any, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	if len(keys) == 1 {
+		out[keys[0]] = redactedValue
+	} else {
+		out[keys[0]] = redactPath(sub, keys[1:])
+	}
+	return out
+}
+
+// Replace occurrences of redacted values in text, e.g. rendered
+// resources. Only string values can be found in text
+func redactText(text string, values map[string]any, paths []string) string {
+	for _, path := range paths {
+		keys := strings.Split(path, ".")
+		var val any = values
+		for _, key := range keys {
+			m, ok := val.(map[string]any)
+			if !ok {
+				val = nil
+				break
+			}
+			val = m[key]
+		}
+		for _, secret := range leafStrings(val) {
+			if secret != "" {
+				text = strings.ReplaceAll(text, secret, redactedValue)
+			}
+		}
+	}
+	return text
+}
+
+// All string values found in a value, recursing into maps and lists
+func leafStrings(val any) []string {
+	switch v := val.(type) {
+	case string:
+		return []string{v}
+	case map[string]any:
+		var out []string
+		for _, sub := range v {
+			out = append(out, leafStrings(sub)...)
+		}
+		return out
+	case []any:
+		var out []string
+		for _, sub := range v {
+			out = append(out, leafStrings(sub)...)
+		}
+		return out
+	}
+	return nil
+}
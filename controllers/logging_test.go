@@ -0,0 +1,129 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+func TestRedactValues(t *testing.T) {
+	values := map[string]any{
+		"name": "foo",
+		"aws": map[string]any{
+			"region":    "eu-north-1",
+			"secretKey": "very-secret",
+		},
+		"tokens": []any{"token1", "token2"},
+	}
+	paths := []string{"aws.secretKey", "tokens", "nonexisting.path", "name.notamap"}
+
+	redacted := redactValues(values, paths)
+	if redacted["name"] != "foo" {
+		t.Fatalf("Redacted unexpected value, got %v, expected 'foo'", redacted["name"])
+	}
+	aws := redacted["aws"].(map[string]any)
+	if aws["secretKey"] != redactedValue || aws["region"] != "eu-north-1" {
+		t.Fatalf("Redaction error, got %v", aws)
+	}
+	if redacted["tokens"] != redactedValue {
+		t.Fatalf("Redaction error, got %v, expected %q", redacted["tokens"], redactedValue)
+	}
+	if values["aws"].(map[string]any)["secretKey"] != "very-secret" {
+		t.Fatalf("Original values modified by redaction")
+	}
+
+	text := redactText("key: very-secret\ntokens: [token1, token2]\nname: foo", values, paths)
+	expected := "key: <redacted>\ntokens: [<redacted>, <redacted>]\nname: foo"
+	if text != expected {
+		t.Fatalf("Redacted text mismatch, got %q, expected %q", text, expected)
+	}
+}
+
+func TestRenderErrorLogRedactsResources(t *testing.T) {
+	var logged strings.Builder
+	logger := funcr.New(func(prefix, args string) {
+		logged.WriteString(args + "\n")
+	}, funcr.Options{})
+	ctx := logr.NewContext(context.Background(), logger)
+
+	scheme := OfflineScheme()
+	r := &GatewayReconciler{client: fake.NewClientBuilder().WithScheme(scheme).Build(), scheme: scheme,
+		recorder: record.NewFakeRecorder(10)}
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw",
+		Annotations: map[string]string{selfapi.DebugAnnotation: "true"}}}
+
+	secret := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"namespace": "default", "name": "credentials"},
+		"data":       map[string]any{"password": "c2VjcmV0LWRhdGE="},
+	}}
+	templates, err := parseTemplates("gwc", "", map[string]string{
+		"credentials": "unused",
+		"broken":      `{{ fail "cannot render" }}`,
+	})
+	if err != nil {
+		t.Fatalf("Cannot parse templates: %v", err)
+	}
+	for _, tmpl := range templates {
+		if tmpl.TemplateName == "credentials" {
+			tmpl.Resources = []ResourceComposite{{Current: secret}}
+		}
+	}
+	values := &TemplateValues{Resources: buildResourceValues(templates)}
+
+	renderTemplates(ctx, r, gw, templates, values, true)
+	if !strings.Contains(logged.String(), "template render error") {
+		t.Fatalf("Render error not logged, got %q", logged.String())
+	}
+	if strings.Contains(logged.String(), "c2VjcmV0LWRhdGE=") {
+		t.Errorf("Secret data logged, got %q", logged.String())
+	}
+	if !strings.Contains(logged.String(), `{"kind"="Secret" "name"="credentials"}`) {
+		t.Errorf("Kind and name of current resources not logged, got %q", logged.String())
+	}
+	if values.Resources["credentials"].([]map[string]any)[0]["data"] == nil {
+		t.Errorf("Original resources modified by redaction")
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Kinds of objects templates of all blueprints may read with the
+// 'lookup' function, e.g. from the --template-lookup-kinds argument
+var TemplateLookupKinds []schema.GroupKind
+
+// An object read by the 'lookup' template function, or a list of
+// objects if name is empty
+type lookupDependency struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// Implements the 'lookup' template function when rendering the
+// templates of a parent resource, recording the objects read such
+// that they can be watched
+type templateLookup struct {
+	ctx     context.Context
+	r       ControllerClient
+	allowed []schema.GroupKind
+	deps    []lookupDependency
+}
+
+func newTemplateLookup(ctx context.Context, r ControllerClient, gwcb *gwcapi.GatewayClassBlueprint) *templateLookup {
+	allowed := slices.Clone(TemplateLookupKinds)
+	for _, kind := range gwcb.Spec.TemplateLookups {
+		allowed = append(allowed, schema.GroupKind{Group: kind.Group, Kind: kind.Kind})
+	}
+	return &templateLookup{ctx: ctx, r: r, allowed: allowed}
+}
+
+// Read an object like the Helm 'lookup' function, i.e. an empty map is
+// returned if the object does not exist, and with an empty name a map
+// with the objects found under 'items' is returned
+func (l *templateLookup) lookup(apiVersion, kind, namespace, name string) (map[string]any, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvk := gv.WithKind(kind)
+	if !slices.Contains(l.allowed, gvk.GroupKind()) {
+		return nil, fmt.Errorf("lookup of %s is not allowed", gvk.GroupKind())
+	}
+	l.deps = append(l.deps, lookupDependency{gvk, namespace, name})
+
+	if name == "" {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gv.WithKind(kind + "List"))
+		if err := l.r.Client().List(l.ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		return list.UnstructuredContent(), nil
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := l.r.Client().Get(l.ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]any{}, nil
+		}
+		return nil, err
+	}
+	return obj.UnstructuredContent(), nil
+}
+
+// Placeholder for the 'lookup' template function when no objects can
+// be read, e.g. when parsing templates
+func lookupUnavailable(_, _, _, _ string) (map[string]any, error) {
+	return nil, fmt.Errorf("lookup is not available")
+}
+
+// Watches objects read by the 'lookup' template function, such that
+// changes trigger reconcile of the parent resources which read
+// them. Watches are started on demand for each kind read, and only
+// cache metadata since 'lookup' reads objects from the API server,
+// i.e. e.g. Secrets read are not held in memory
+type lookupWatcher struct {
+	cache cache.Cache
+	ctrl  controller.Controller
+
+	mu      sync.Mutex
+	watched map[schema.GroupVersionKind]bool
+	deps    map[types.NamespacedName]map[lookupDependency]bool
+}
+
+func newLookupWatcher(c cache.Cache, ctrl controller.Controller) *lookupWatcher {
+	return &lookupWatcher{
+		cache:   c,
+		ctrl:    ctrl,
+		watched: map[schema.GroupVersionKind]bool{},
+		deps:    map[types.NamespacedName]map[lookupDependency]bool{},
+	}
+}
+
+// Replace the objects read by a parent resource, starting watches for
+// kinds not watched yet. Objects read by a previous reconcile and not
+// read anymore are forgotten. A nil watcher does nothing
+func (w *lookupWatcher) track(parent types.NamespacedName, deps []lookupDependency) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(deps) == 0 {
+		delete(w.deps, parent)
+		return nil
+	}
+	set := make(map[lookupDependency]bool, len(deps))
+	for _, dep := range deps {
+		set[dep] = true
+		if w.watched[dep.gvk] {
+			continue
+		}
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(dep.gvk)
+		if err := w.ctrl.Watch(source.Kind[client.Object](w.cache, obj,
+			handler.EnqueueRequestsFromMapFunc(w.parentsOf(dep.gvk.GroupKind())))); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", dep.gvk, err)
+		}
+		w.watched[dep.gvk] = true
+	}
+	w.deps[parent] = set
+	return nil
+}
+
+// Map objects of a kind to the parent resources which read them. The
+// kind is given by the watch since metadata-only objects may not
+// carry it
+func (w *lookupWatcher) parentsOf(gk schema.GroupKind) handler.MapFunc {
+	return func(_ context.Context, obj client.Object) []reconcile.Request {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		var requests []reconcile.Request
+		for parent, deps := range w.deps {
+			for dep := range deps {
+				if dep.gvk.GroupKind() == gk && (dep.namespace == "" || dep.namespace == obj.GetNamespace()) &&
+					(dep.name == "" || dep.name == obj.GetName()) {
+					requests = append(requests, reconcile.Request{NamespacedName: parent})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
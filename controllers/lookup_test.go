@@ -0,0 +1,122 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func TestTemplateLookup(t *testing.T) {
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vpc", Namespace: "infra"}, Data: map[string]string{"id": "vpc-1234"}},
+		).Build(),
+		scheme: scheme,
+	}
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	gwcb.Spec.TemplateLookups = []gwcapi.TemplateLookupKind{{Kind: "ConfigMap"}}
+	lookup := newTemplateLookup(context.Background(), r, gwcb)
+
+	tmplStr := `
+vpc: {{ (lookup "v1" "ConfigMap" "infra" "vpc").data.id }}
+missing: {{ empty (lookup "v1" "ConfigMap" "infra" "missing") }}
+count: {{ len (lookup "v1" "ConfigMap" "infra" "").items }}`
+	tmpl, err := parseSingleTemplate("test", "", tmplStr)
+	if err != nil {
+		t.Fatalf("Cannot parse template: %v", err)
+	}
+	res, err := template2maps(tmpl, &TemplateValues{lookup: lookup})
+	if err != nil {
+		t.Fatalf("Cannot render template: %v", err)
+	}
+	expected := map[string]any{"vpc": "vpc-1234", "missing": true, "count": int64(1)}
+	if !reflect.DeepEqual(res[0], expected) {
+		t.Errorf("Got %+v, expected %+v", res[0], expected)
+	}
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	expectedDeps := []lookupDependency{{gvk, "infra", "vpc"}, {gvk, "infra", "missing"}, {gvk, "infra", ""}}
+	if !reflect.DeepEqual(lookup.deps, expectedDeps) {
+		t.Errorf("Got dependencies %+v, expected %+v", lookup.deps, expectedDeps)
+	}
+
+	// Kinds not allowed cannot be read
+	if _, err := lookup.lookup("v1", "Secret", "infra", "vpc"); err == nil {
+		t.Errorf("Expected error for lookup of kind not allowed")
+	}
+	savedKinds := TemplateLookupKinds
+	TemplateLookupKinds = []schema.GroupKind{{Kind: "Secret"}}
+	defer func() { TemplateLookupKinds = savedKinds }()
+	if _, err := newTemplateLookup(context.Background(), r, gwcb).lookup("v1", "Secret", "infra", "vpc"); err != nil {
+		t.Errorf("Lookup of kind allowed by controller: %v", err)
+	}
+
+	// Lookup is not available without a cluster client
+	tmpl, err = parseSingleTemplate("test", "", tmplStr)
+	if err != nil {
+		t.Fatalf("Cannot parse template: %v", err)
+	}
+	if _, err := template2maps(tmpl, &TemplateValues{}); err == nil {
+		t.Errorf("Expected error without lookup")
+	}
+}
+
+func TestLookupWatcherParentsOf(t *testing.T) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	w := newLookupWatcher(nil, nil)
+	w.deps[types.NamespacedName{Namespace: "foo", Name: "object"}] = map[lookupDependency]bool{{gvk, "infra", "vpc"}: true}
+	w.deps[types.NamespacedName{Namespace: "foo", Name: "list"}] = map[lookupDependency]bool{{gvk, "infra", ""}: true}
+	w.deps[types.NamespacedName{Namespace: "foo", Name: "other"}] = map[lookupDependency]bool{{gvk, "other", "vpc"}: true}
+
+	// Metadata-only objects from the watch, without kind
+	obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "vpc"}}
+	parents := map[string]bool{}
+	for _, req := range w.parentsOf(gvk.GroupKind())(context.Background(), obj) {
+		parents[req.Name] = true
+	}
+	if !reflect.DeepEqual(parents, map[string]bool{"object": true, "list": true}) {
+		t.Errorf("Got parents %v, expected object and list", parents)
+	}
+	if requests := w.parentsOf(schema.GroupKind{Kind: "Secret"})(context.Background(), obj); len(requests) != 0 {
+		t.Errorf("Got parents %v for other kind, expected none", requests)
+	}
+}
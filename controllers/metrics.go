@@ -32,43 +32,200 @@ limitations under the License.
 package controllers
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// Labels used for template related metrics
+var templateLabels = []string{"gatewayclass", "template"}
+
 var (
-	metricPatchApply = prometheus.NewCounter(
+	metricPatchApply = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bifrost_patchapply_total",
 			Help: "Number of server-side patch operations",
 		},
+		templateLabels,
 	)
-	metricPatchApplyErrs = prometheus.NewCounter(
+	metricPatchApplyErrs = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bifrost_patchapply_errors_total",
 			Help: "Number of server-side patch errors",
 		},
+		templateLabels,
+	)
+	metricPatchApplyDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bifrost_patchapply_duration_seconds",
+			Help:    "Latency of server-side patch operations",
+			Buckets: prometheus.DefBuckets,
+		},
+		templateLabels,
 	)
-	metricTemplateErrs = prometheus.NewCounter(
+	metricTemplateErrs = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bifrost_template_errors_total",
 			Help: "Number of template render errors",
 		},
+		templateLabels,
 	)
-	metricTemplateParseErrs = prometheus.NewCounter(
+	metricTemplateParseErrs = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bifrost_template_parse_errors_total",
 			Help: "Number of template parse errors",
 		},
+		templateLabels,
 	)
-	metricResourceGet = prometheus.NewCounter(
+	metricTemplateRenderDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bifrost_template_render_duration_seconds",
+			Help:    "Duration of template rendering",
+			Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+		},
+		templateLabels,
+	)
+	metricResourceGet = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "bifrost_resource_get_total",
 			Help: "Number of resources fetched to use as dependency in templates",
 		},
+		templateLabels,
 	)
+	metricGateways = prometheus.NewDesc("bifrost_gateways",
+		"Number of managed Gateways", []string{"gatewayclass"}, nil)
+	metricGatewaysNotReady = prometheus.NewDesc("bifrost_gateways_not_ready",
+		"Number of managed Gateways which are not ready", []string{"gatewayclass"}, nil)
+	metricHTTPRoutes = prometheus.NewDesc("bifrost_httproutes",
+		"Number of managed HTTPRoutes", []string{"gatewayclass"}, nil)
+	metricChildResources = prometheus.NewDesc("bifrost_child_resources",
+		"Number of child resources rendered from templates", []string{"gatewayclass"}, nil)
 )
 
 func init() {
-	metrics.Registry.MustRegister(metricPatchApply, metricPatchApplyErrs, metricTemplateErrs, metricResourceGet)
+	metrics.Registry.MustRegister(metricPatchApply, metricPatchApplyErrs, metricPatchApplyDuration,
+		metricTemplateErrs, metricTemplateParseErrs, metricTemplateRenderDuration, metricResourceGet,
+		gaugeCollector{})
+}
+
+// Gauges are calculated from the latest known state of each managed
+// Gateway and HTTPRoute
+type gatewayMetricsState struct {
+	gatewayClass string
+	children     int
+	ready        bool
+}
+
+// A HTTPRoute may have parents of different GatewayClasses, hence the
+// number of child resources is tracked per GatewayClass
+type httpRouteMetricsState map[string]int
+
+var (
+	metricsStateLock     sync.Mutex
+	gatewayMetricsData   = map[types.NamespacedName]gatewayMetricsState{}
+	httpRouteMetricsData = map[types.NamespacedName]httpRouteMetricsState{}
+)
+
+// Update gauges with state of a managed Gateway
+func setGatewayMetrics(nn types.NamespacedName, gatewayClass string, children int, ready bool) {
+	metricsStateLock.Lock()
+	defer metricsStateLock.Unlock()
+	gatewayMetricsData[nn] = gatewayMetricsState{gatewayClass, children, ready}
+}
+
+// Update gauges with a managed Gateway being not ready, e.g. when
+// reconcile fails. The number of child resources is kept from the
+// last update
+func setGatewayNotReadyMetrics(nn types.NamespacedName, gatewayClass string) {
+	metricsStateLock.Lock()
+	defer metricsStateLock.Unlock()
+	state := gatewayMetricsData[nn]
+	state.gatewayClass = gatewayClass
+	state.ready = false
+	gatewayMetricsData[nn] = state
+}
+
+// Remove Gateway from gauges, e.g. when deleted or no longer managed by us
+func deleteGatewayMetrics(nn types.NamespacedName) {
+	metricsStateLock.Lock()
+	defer metricsStateLock.Unlock()
+	delete(gatewayMetricsData, nn)
+}
+
+// Update gauges with state of a managed HTTPRoute
+func setHTTPRouteMetrics(nn types.NamespacedName, children httpRouteMetricsState) {
+	metricsStateLock.Lock()
+	defer metricsStateLock.Unlock()
+	httpRouteMetricsData[nn] = children
+}
+
+// Remove HTTPRoute from gauges, e.g. when deleted or no longer managed by us
+func deleteHTTPRouteMetrics(nn types.NamespacedName) {
+	metricsStateLock.Lock()
+	defer metricsStateLock.Unlock()
+	delete(httpRouteMetricsData, nn)
+}
+
+// Collector of the gauges, calculated from the state of Gateways and
+// HTTPRoutes when collected, i.e. scrapes see consistent values
+type gaugeCollector struct{}
+
+func (gaugeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricGateways
+	ch <- metricGatewaysNotReady
+	ch <- metricHTTPRoutes
+	ch <- metricChildResources
+}
+
+func (gaugeCollector) Collect(ch chan<- prometheus.Metric) {
+	type classGauges struct {
+		gateways, notReady, httpRoutes, children int
+	}
+	classes := map[string]*classGauges{}
+	class := func(name string) *classGauges {
+		if classes[name] == nil {
+			classes[name] = &classGauges{}
+		}
+		return classes[name]
+	}
+
+	metricsStateLock.Lock()
+	for _, gw := range gatewayMetricsData {
+		gauges := class(gw.gatewayClass)
+		gauges.gateways++
+		if !gw.ready {
+			gauges.notReady++
+		}
+		gauges.children += gw.children
+	}
+	for _, rt := range httpRouteMetricsData {
+		for gatewayClass, children := range rt {
+			gauges := class(gatewayClass)
+			gauges.httpRoutes++
+			gauges.children += children
+		}
+	}
+	metricsStateLock.Unlock()
+
+	for name, gauges := range classes {
+		if gauges.gateways > 0 {
+			ch <- prometheus.MustNewConstMetric(metricGateways, prometheus.GaugeValue, float64(gauges.gateways), name)
+			ch <- prometheus.MustNewConstMetric(metricGatewaysNotReady, prometheus.GaugeValue, float64(gauges.notReady), name)
+		}
+		if gauges.httpRoutes > 0 {
+			ch <- prometheus.MustNewConstMetric(metricHTTPRoutes, prometheus.GaugeValue, float64(gauges.httpRoutes), name)
+		}
+		ch <- prometheus.MustNewConstMetric(metricChildResources, prometheus.GaugeValue, float64(gauges.children), name)
+	}
+}
+
+// Number of child resources rendered from templates
+func childResourceCount(templates []*ResourceTemplateState) int {
+	cnt := 0
+	for _, tmpl := range templates {
+		cnt += len(tmpl.Resources)
+	}
+	return cnt
 }
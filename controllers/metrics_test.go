@@ -0,0 +1,102 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Value of a gauge collected by gaugeCollector, -1 if not collected
+func gaugeValue(t *testing.T, desc *prometheus.Desc, gatewayClass string) float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	gaugeCollector{}.Collect(ch)
+	close(ch)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("Cannot write metric: %v", err)
+		}
+		if metric.Desc() == desc && m.GetLabel()[0].GetValue() == gatewayClass {
+			return m.GetGauge().GetValue()
+		}
+	}
+	return -1
+}
+
+func TestGaugeMetrics(t *testing.T) {
+	gw1 := types.NamespacedName{Namespace: "metrics-test", Name: "gw1"}
+	gw2 := types.NamespacedName{Namespace: "metrics-test", Name: "gw2"}
+	rt1 := types.NamespacedName{Namespace: "metrics-test", Name: "rt1"}
+
+	setGatewayMetrics(gw1, "metrics-class", 3, true)
+	setGatewayMetrics(gw2, "metrics-class", 2, false)
+	setHTTPRouteMetrics(rt1, httpRouteMetricsState{"metrics-class": 1, "other-metrics-class": 4})
+
+	if v := gaugeValue(t, metricGateways, "metrics-class"); v != 2 {
+		t.Fatalf("Gateways gauge mismatch, got %v, expected 2", v)
+	}
+	if v := gaugeValue(t, metricGatewaysNotReady, "metrics-class"); v != 1 {
+		t.Fatalf("Not-ready Gateways gauge mismatch, got %v, expected 1", v)
+	}
+	if v := gaugeValue(t, metricHTTPRoutes, "other-metrics-class"); v != 1 {
+		t.Fatalf("HTTPRoutes gauge mismatch, got %v, expected 1", v)
+	}
+	if v := gaugeValue(t, metricChildResources, "metrics-class"); v != 6 {
+		t.Fatalf("Child resources gauge mismatch, got %v, expected 6", v)
+	}
+
+	deleteGatewayMetrics(gw2)
+	deleteHTTPRouteMetrics(rt1)
+	if v := gaugeValue(t, metricGatewaysNotReady, "metrics-class"); v != 0 {
+		t.Fatalf("Not-ready Gateways gauge mismatch after delete, got %v, expected 0", v)
+	}
+	if v := gaugeValue(t, metricChildResources, "metrics-class"); v != 3 {
+		t.Fatalf("Child resources gauge mismatch after delete, got %v, expected 3", v)
+	}
+
+	setGatewayNotReadyMetrics(gw1, "metrics-class")
+	if v := gaugeValue(t, metricGatewaysNotReady, "metrics-class"); v != 1 {
+		t.Fatalf("Not-ready Gateways gauge mismatch after failure, got %v, expected 1", v)
+	}
+	if v := gaugeValue(t, metricChildResources, "metrics-class"); v != 3 {
+		t.Fatalf("Child resources gauge mismatch after failure, got %v, expected 3", v)
+	}
+	deleteGatewayMetrics(gw1)
+	if v := gaugeValue(t, metricGateways, "metrics-class"); v != -1 {
+		t.Fatalf("Gateways gauge of class without Gateways collected, got %v", v)
+	}
+}
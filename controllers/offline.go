@@ -0,0 +1,449 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Namespace used for namespaced objects without a namespace when rendering offline
+const OfflineDefaultNamespace = "default"
+
+// Input for rendering templates without a cluster, e.g. from the
+// `bifrost render` command
+type OfflineInput struct {
+	// GatewayClass, GatewayClassBlueprint, Gateway, HTTPRoutes and
+	// GatewayClassConfig/GatewayConfig policies
+	Objects []client.Object
+
+	// Gateway to render. May be left empty if Objects contain a single Gateway
+	Gateway types.NamespacedName
+
+	// HTTPRoute to explain values for. If empty, values for Gateway templates are explained
+	HTTPRoute types.NamespacedName
+
+	// Fake current state of child resources, indexed by template
+	// name. Made available to templates as `.Resources`. Templates
+	// without fake state use their own rendered resources from
+	// the previous render pass
+	Resources map[string][]map[string]any
+}
+
+// Resources rendered offline from a single template
+type OfflineResult struct {
+	// Kind of parent resource, i.e. `Gateway` or `HTTPRoute`
+	ParentKind string
+
+	// Parent resource the template was rendered for
+	Parent types.NamespacedName
+
+	// Name of template (from template key in GatewayClassBlueprint)
+	TemplateName string
+
+	// Rendered resources
+	Resources []map[string]any
+
+	// Error from final render attempt, nil if successful
+	RenderErr error
+
+	// Values of the parent ignored due to type conflicts, see
+	// mergeValueSources. Only set for the first result of each parent
+	ValueConflicts []string
+}
+
+// Minimal ControllerClient for use without a cluster
+type offlineClient struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func (r *offlineClient) Client() client.Client {
+	return r.client
+}
+
+func (r *offlineClient) Scheme() *runtime.Scheme {
+	return r.scheme
+}
+
+// Scheme with the types understood when rendering offline
+func OfflineScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(gatewayapi.Install(scheme))
+	utilruntime.Must(gatewayapiv1b1.Install(scheme))
+	utilruntime.Must(gwcapi.AddToScheme(scheme))
+	return scheme
+}
+
+// Decode multi-document YAML or JSON into typed objects. Gateway-API
+// resources of older API versions are read as v1 if the kind exists
+// in v1, since the controller use v1 where possible. Namespaced
+// objects without a namespace are placed in OfflineDefaultNamespace
+func DecodeOfflineObjects(scheme *runtime.Scheme, data []byte) ([]client.Object, error) {
+	var objs []client.Object
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		u := unstructured.Unstructured{}
+		if err := decoder.Decode(&u.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("cannot decode document: %w", err)
+		}
+		if len(u.Object) == 0 {
+			continue // Empty document
+		}
+		gvk := u.GroupVersionKind()
+		if gvk.Group == gatewayapi.GroupName && scheme.Recognizes(gatewayapi.SchemeGroupVersion.WithKind(gvk.Kind)) {
+			gvk.Version = gatewayapi.GroupVersion.Version
+			u.SetGroupVersionKind(gvk)
+		}
+		robj, err := scheme.New(gvk)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported object %s %q: %w", gvk.Kind, u.GetName(), err)
+		}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, robj); err != nil {
+			return nil, fmt.Errorf("cannot convert %s %q: %w", gvk.Kind, u.GetName(), err)
+		}
+		obj, ok := robj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unsupported object %s %q", gvk.Kind, u.GetName())
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		switch obj.(type) {
+		case *gatewayapi.GatewayClass, *gwcapi.GatewayClassBlueprint, *gwcapi.ClusterGatewayClassConfig, *corev1.Namespace:
+			// Cluster-scoped
+		default:
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(OfflineDefaultNamespace)
+			}
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// Render Gateway and HTTPRoute templates without a cluster. Values
+// are looked up with the same precedence rules as the controller use
+// and HTTPRoutes attached to the Gateway are rendered with the
+// HTTPRoute templates. Objects are served from a fake client, i.e. no
+// resources are applied and no current state is read.
+func RenderOffline(ctx context.Context, input *OfflineInput) ([]OfflineResult, error) {
+	r, gw, gwc, gwcb, err := offlineLookup(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := lookupHTTPRoutes(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up routes: %w", err)
+	}
+	gwRoutes := uniqueHTTPRoutes(filterHTTPRoutesForGateway(gw, routes))
+	sort.SliceStable(gwRoutes, func(i, j int) bool {
+		return client.ObjectKeyFromObject(gwRoutes[i]).String() < client.ObjectKeyFromObject(gwRoutes[j]).String()
+	})
+	union, isect := combineHostnames(gw, gwRoutes)
+	sort.Strings(union) // Predictable output
+	sort.Strings(isect)
+	attachedRoutes, err := attachedHTTPRoutes(ctx, r, gw, routes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up attached routes: %w", err)
+	}
+
+	lookup, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot lookup values: %w", err)
+	}
+
+	gatewayMap, err := parentToMap(gw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert gateway to map: %w", err)
+	}
+	nsMap, err := lookupNamespaceMap(ctx, r, gw.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("cannot lookup namespace: %w", err)
+	}
+	gwcMap, err := objectToMap(gwc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert gatewayclass to map: %w", err)
+	}
+	gwcbMap, err := blueprintToMap(gwcb)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert gatewayclassblueprint to map: %w", err)
+	}
+
+	templateValues := TemplateValues{
+		Gateway:          &gatewayMap,
+		HTTPRoutes:       attachedRoutes,
+		Namespace:        nsMap,
+		GatewayNamespace: nsMap,
+		GatewayClass:     gwcMap,
+		Blueprint:        gwcbMap,
+		Values:           lookup.values,
+		Hostnames: TemplateHostnameValues{
+			Union:        union,
+			Intersection: isect,
+		},
+		sensitivePaths: lookup.sensitivePaths,
+		lookup:         newTemplateLookup(ctx, r, gwcb),
+	}
+
+	templates, err := parseTemplates(gwc.Name, gwcb.Spec.Helpers, gwcb.Spec.GatewayTemplate.ResourceTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse gateway templates: %w", err)
+	}
+	results := renderOfflineTemplates(templates, &templateValues, input.Resources,
+		"Gateway", client.ObjectKeyFromObject(gw), lookup.conflicts)
+
+	for _, rt := range gwRoutes {
+		rtMap, err := parentToMap(rt)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert httproute to map: %w", err)
+		}
+		rtValues := templateValues
+		rtValues.HTTPRoute = rtMap
+		rtValues.HTTPRoutes = nil
+		if rtValues.Namespace, err = lookupNamespaceMap(ctx, r, rt.Namespace); err != nil {
+			return nil, fmt.Errorf("cannot lookup namespace of httproute: %w", err)
+		}
+		rtKey := client.ObjectKeyFromObject(rt)
+		rtLookup, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name, &rtKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot lookup values for httproute %s: %w", rtKey, err)
+		}
+		rtValues.Values = rtLookup.values
+		rtValues.sensitivePaths = rtLookup.sensitivePaths
+		if rtValues.Backends, _, err = resolveBackends(ctx, r, rt, gwcb.Spec.BackendEndpointSlices); err != nil {
+			return nil, fmt.Errorf("cannot resolve backends of httproute %s: %w", rtKey, err)
+		}
+
+		templates, err := parseTemplates(gwc.Name, gwcb.Spec.Helpers, gwcb.Spec.HTTPRouteTemplate.ResourceTemplates)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse httproute templates: %w", err)
+		}
+		results = append(results, renderOfflineTemplates(templates, &rtValues, input.Resources,
+			"HTTPRoute", client.ObjectKeyFromObject(rt), rtLookup.conflicts)...)
+	}
+
+	return results, nil
+}
+
+// Explain the effective template values of a Gateway, or a HTTPRoute
+// attached to it, without a cluster, i.e. which GatewayClassBlueprint or policy supplied each
+// value and which values it masked. Values ignored due to type
+// conflicts are also returned
+func ExplainOffline(ctx context.Context, input *OfflineInput) ([]ValueOrigin, []string, error) {
+	r, gw, gwc, gwcb, err := offlineLookup(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	var route *types.NamespacedName
+	if input.HTTPRoute.Name != "" {
+		route = &input.HTTPRoute
+		if route.Namespace == "" {
+			route.Namespace = OfflineDefaultNamespace
+		}
+	}
+	sources, _, err := lookupValueSources(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name, route)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lookup values: %w", err)
+	}
+	_, origins, conflicts, err := mergeValueSources(sources, gwcb.Spec.ValueMergeStrategies, true)
+	return origins, conflicts, err
+}
+
+// Setup fake client from input and lookup Gateway, GatewayClass and GatewayClassBlueprint
+func offlineLookup(ctx context.Context, input *OfflineInput) (ControllerClient, *gatewayapi.Gateway,
+	*gatewayapi.GatewayClass, *gwcapi.GatewayClassBlueprint, error) {
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(input.Objects...).Build(),
+		scheme: scheme,
+	}
+
+	gw, err := lookupOfflineGateway(ctx, r, input.Gateway)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot lookup GatewayClass %q: %w", gw.Spec.GatewayClassName, err)
+	}
+
+	gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc, readBlueprintSource)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot lookup GatewayClassBlueprint for GatewayClass %q: %w", gwc.Name, err)
+	}
+
+	return r, gw, gwc, gwcb, nil
+}
+
+// Find the Gateway to render, either by name or as the only Gateway available
+func lookupOfflineGateway(ctx context.Context, r ControllerClient, nn types.NamespacedName) (*gatewayapi.Gateway, error) {
+	if nn.Name != "" {
+		if nn.Namespace == "" {
+			nn.Namespace = OfflineDefaultNamespace
+		}
+		gw, err := lookupGateway(ctx, r, gatewayapi.ObjectName(nn.Name), nn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("cannot lookup Gateway %q: %w", nn, err)
+		}
+		return gw, nil
+	}
+
+	var gwList gatewayapi.GatewayList
+	if err := r.Client().List(ctx, &gwList); err != nil {
+		return nil, err
+	}
+	if len(gwList.Items) != 1 {
+		return nil, fmt.Errorf("expected a single Gateway, found %d - specify which Gateway to render", len(gwList.Items))
+	}
+	return &gwList.Items[0], nil
+}
+
+// Remove duplicates, e.g. from HTTPRoutes with multiple parentRefs to the same Gateway
+func uniqueHTTPRoutes(rtList []*gatewayapi.HTTPRoute) []*gatewayapi.HTTPRoute {
+	rtOut := make([]*gatewayapi.HTTPRoute, 0, len(rtList))
+	seen := map[types.NamespacedName]bool{}
+	for _, rt := range rtList {
+		nn := client.ObjectKeyFromObject(rt)
+		if !seen[nn] {
+			seen[nn] = true
+			rtOut = append(rtOut, rt)
+		}
+	}
+	return rtOut
+}
+
+// Offline equivalent of the multi-pass render loop in the
+// reconcilers. Since nothing is applied, `.Resources` are taken from
+// the fake resource state or, if not given for a template, from the
+// resources rendered in the previous pass
+func renderOfflineTemplates(templates []*ResourceTemplateState, values *TemplateValues,
+	resources map[string][]map[string]any, parentKind string, parent types.NamespacedName,
+	conflicts []string) []OfflineResult {
+	rendered := map[string][]map[string]any{}
+	renderErrs := map[string]error{}
+
+	for attempt := 0; attempt < len(templates); attempt++ {
+		values.Resources = map[string]any{}
+		for _, tmpl := range templates {
+			if res, found := resources[tmpl.TemplateName]; found {
+				values.Resources[tmpl.TemplateName] = res
+			} else if res, found := rendered[tmpl.TemplateName]; found {
+				values.Resources[tmpl.TemplateName] = res
+			} else {
+				values.Resources[tmpl.TemplateName] = []map[string]any{}
+			}
+		}
+		for _, tmpl := range templates {
+			if _, found := rendered[tmpl.TemplateName]; found {
+				continue
+			}
+			res, err := template2maps(tmpl.Template, values)
+			if err != nil {
+				renderErrs[tmpl.TemplateName] = err
+				continue
+			}
+			delete(renderErrs, tmpl.TemplateName)
+			rendered[tmpl.TemplateName] = res
+		}
+	}
+
+	results := make([]OfflineResult, 0, len(templates))
+	for _, tmpl := range templates {
+		results = append(results, OfflineResult{
+			ParentKind:   parentKind,
+			Parent:       parent,
+			TemplateName: tmpl.TemplateName,
+			Resources:    rendered[tmpl.TemplateName],
+			RenderErr:    renderErrs[tmpl.TemplateName],
+		})
+	}
+	if len(results) > 0 {
+		results[0].ValueConflicts = conflicts
+	}
+	return results
+}
+
+// Write results as multi-document YAML with the source of each
+// resource as a comment. Render errors are written as comments
+func WriteOfflineResults(w io.Writer, results []OfflineResult) error {
+	for _, res := range results {
+		for _, conflict := range res.ValueConflicts {
+			if _, err := fmt.Fprintf(w, "# Warning: %s %s: %s\n", res.ParentKind, res.Parent, conflict); err != nil {
+				return err
+			}
+		}
+		source := fmt.Sprintf("# Source: %s %s, template: %s\n", res.ParentKind, res.Parent, res.TemplateName)
+		if res.RenderErr != nil {
+			if _, err := fmt.Fprintf(w, "%s# Error: %s\n---\n", source,
+				strings.ReplaceAll(res.RenderErr.Error(), "\n", "\n# ")); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, obj := range res.Resources {
+			data, err := sigsyaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s%s---\n", source, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+)
+
+var offlineObjects = `
+apiVersion: gateway.networking.k8s.io/v1
+kind: GatewayClass
+metadata:
+  name: test
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: test
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: test
+spec:
+  values:
+    default:
+      replicas: 1
+      suffix: bp
+  gatewayTemplate:
+    resourceTemplates:
+      configMap: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-{{ .Values.suffix }}
+        data:
+          replicas: "{{ .Values.replicas }}"
+          hostnames: "{{ join "," .Hostnames.Union }}"
+          team: {{ .Namespace.metadata.labels.team }}
+          class: {{ .GatewayClass.metadata.name }}
+          routes: "{{ range .HTTPRoutes }}{{ .HTTPRoute.metadata.name }}:{{ join "," .Listeners }}{{ end }}"
+          blueprint: {{ .Blueprint.metadata.name }}-{{ hasKey .Blueprint "spec" }}
+      dependent: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ (index .Resources.configMap 0).metadata.name }}-dep
+        data:
+          status: {{ dig "status" "phase" "none" (index .Resources.configMap 0) }}
+  httpRouteTemplate:
+    resourceTemplates:
+      route: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .HTTPRoute.metadata.name }}-{{ .Values.suffix }}
+        data:
+          namespace: {{ .Namespace.metadata.name }}
+          team: {{ .GatewayNamespace.metadata.labels.team }}
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: default
+  labels:
+    team: foo
+---
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: gw
+spec:
+  gatewayClassName: test
+  listeners:
+  - name: web
+    port: 80
+    protocol: HTTP
+    hostname: "*.example.com"
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: rt
+spec:
+  hostnames:
+  - foo.example.com
+  parentRefs:
+  - kind: Gateway
+    name: gw
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayConfig
+metadata:
+  name: gw-config
+spec:
+  override:
+    replicas: 3
+  targetRef:
+    group: gateway.networking.k8s.io
+    kind: Gateway
+    name: gw
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayConfig
+metadata:
+  name: rt-config
+spec:
+  default:
+    suffix: route
+  targetRef:
+    group: gateway.networking.k8s.io
+    kind: HTTPRoute
+    name: rt
+`
+
+func TestRenderOffline(t *testing.T) {
+	objs, err := DecodeOfflineObjects(OfflineScheme(), []byte(offlineObjects))
+	if err != nil {
+		t.Fatalf("Cannot decode objects: %v", err)
+	}
+	if len(objs) != 7 {
+		t.Fatalf("Decoded object count, got %v, expected 7", len(objs))
+	}
+
+	results, err := RenderOffline(context.Background(), &OfflineInput{Objects: objs})
+	if err != nil {
+		t.Fatalf("Cannot render offline: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Result count, got %v, expected 3", len(results))
+	}
+	for _, res := range results {
+		if res.RenderErr != nil {
+			t.Fatalf("Unexpected render error in template %q: %v", res.TemplateName, res.RenderErr)
+		}
+		if len(res.Resources) != 1 {
+			t.Fatalf("Resource count of template %q, got %v, expected 1", res.TemplateName, len(res.Resources))
+		}
+	}
+	cm := results[0].Resources[0]
+	if cm["metadata"].(map[string]any)["name"] != "gw-bp" {
+		t.Fatalf("Rendered name error, got %v, expected 'gw-bp'", cm["metadata"])
+	}
+	data := cm["data"].(map[string]any)
+	if data["replicas"] != "3" || data["hostnames"] != "*.example.com,foo.example.com" || data["team"] != "foo" {
+		t.Fatalf("Rendered data error, got %v", data)
+	}
+	if data["class"] != "test" || data["blueprint"] != "test-false" || data["routes"] != "rt:web" {
+		t.Fatalf("Rendered GatewayClass or blueprint error, got %v", data)
+	}
+	// Rendered from previous pass since no fake resources given
+	dep := results[1].Resources[0]
+	if dep["data"].(map[string]any)["status"] != "none" {
+		t.Fatalf("Rendered dependent error, got %v", dep["data"])
+	}
+	if results[2].ParentKind != "HTTPRoute" || results[2].Parent.String() != "default/rt" {
+		t.Fatalf("HTTPRoute parent error, got %v %v", results[2].ParentKind, results[2].Parent)
+	}
+	// Policy targeting HTTPRoute only applies to HTTPRoute templates
+	if name := results[2].Resources[0]["metadata"].(map[string]any)["name"]; name != "rt-route" {
+		t.Fatalf("Rendered HTTPRoute name error, got %v, expected 'rt-route'", name)
+	}
+	if data := results[2].Resources[0]["data"].(map[string]any); data["namespace"] != "default" || data["team"] != "foo" {
+		t.Fatalf("Rendered HTTPRoute namespace error, got %v", data)
+	}
+
+	// Fake resources are used in place of rendered resources
+	results, err = RenderOffline(context.Background(), &OfflineInput{
+		Objects: objs,
+		Resources: map[string][]map[string]any{
+			"configMap": {{"metadata": map[string]any{"name": "fake"}, "status": map[string]any{"phase": "Ready"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Cannot render offline: %v", err)
+	}
+	dep = results[1].Resources[0]
+	if dep["metadata"].(map[string]any)["name"] != "fake-dep" || dep["data"].(map[string]any)["status"] != "Ready" {
+		t.Fatalf("Rendered dependent error with fake resources, got %v", dep)
+	}
+}
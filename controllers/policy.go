@@ -0,0 +1,260 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// A policy attached to e.g. a GatewayClass, Namespace or Gateway
+// through a GatewayClassConfig or GatewayConfig
+type attachedPolicy struct {
+	kind       string
+	obj        client.Object
+	values     *gwcapi.TemplateValues
+	conditions *[]metav1.Condition
+
+	// Description of conflicts with policies of higher precedence
+	// at the same level of the hierarchy, empty if no conflicts
+	conflict string
+}
+
+func gatewayClassConfigPolicy(gwcc *gwcapi.GatewayClassConfig) *attachedPolicy {
+	return &attachedPolicy{"GatewayClassConfig", gwcc, &gwcc.Spec.TemplateValues, &gwcc.Status.Conditions, ""}
+}
+
+func clusterGatewayClassConfigPolicy(cgwcc *gwcapi.ClusterGatewayClassConfig) *attachedPolicy {
+	return &attachedPolicy{"ClusterGatewayClassConfig", cgwcc, &cgwcc.Spec.TemplateValues, &cgwcc.Status.Conditions, ""}
+}
+
+func gatewayConfigPolicy(gwc *gwcapi.GatewayConfig) *attachedPolicy {
+	return &attachedPolicy{"GatewayConfig", gwc, &gwc.Spec.TemplateValues, &gwc.Status.Conditions, ""}
+}
+
+// Order policies at the same level of the hierarchy, i.e. targeting
+// the same resource, by increasing precedence following GEP-713: The
+// oldest policy by creation timestamp has highest precedence and ties
+// are broken by alphabetical order of namespace/name. Policies with
+// values masked by a policy of higher precedence are marked as
+// conflicted. Note that only the masked values are not used, i.e. the
+// remaining values of a conflicted policy are still used.
+func resolvePolicyConflicts(policies []*attachedPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		ti, tj := policies[i].obj.GetCreationTimestamp(), policies[j].obj.GetCreationTimestamp()
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti) // Newest first
+		}
+		return client.ObjectKeyFromObject(policies[i].obj).String() > client.ObjectKeyFromObject(policies[j].obj).String()
+	})
+
+	for idx, pol := range policies {
+		pol.conflict = ""
+		var conflicts []string
+		for _, winner := range policies[idx+1:] {
+			paths := overlappingPaths(pol.values.Default, winner.values.Default)
+			paths = append(paths, overlappingPaths(pol.values.Override, winner.values.Override)...)
+			if len(paths) > 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s by %s %s", strings.Join(paths, ","),
+					winner.kind, objectRef(winner.obj)))
+			}
+		}
+		if len(conflicts) > 0 {
+			pol.conflict = "values masked by policies with higher precedence: " + strings.Join(conflicts, "; ")
+		}
+	}
+}
+
+// Leaf value paths of 'a' which overlap values in 'b', i.e. are equal to,
+// an ancestor or a descendant of a leaf value path in 'b'
+func overlappingPaths(a, b *apiextensionsv1.JSON) []string {
+	leavesA, leavesB := rawLeafPaths(a), rawLeafPaths(b)
+	var paths []string
+	for _, pa := range leavesA {
+		for _, pb := range leavesB {
+			if pa == pb || strings.HasPrefix(pa, pb+".") || strings.HasPrefix(pb, pa+".") {
+				paths = append(paths, pa)
+				break
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func rawLeafPaths(raw *apiextensionsv1.JSON) []string {
+	if raw == nil {
+		return nil
+	}
+	values := map[string]any{}
+	if err := json.Unmarshal(raw.Raw, &values); err != nil {
+		return nil // Reported when merging values
+	}
+	leaves := leafValues(nil, values)
+	paths := make([]string, 0, len(leaves))
+	for _, leaf := range leaves {
+		paths = append(paths, strings.Join(leaf.path, "."))
+	}
+	return paths
+}
+
+// A resource targeted by policies, e.g. a Gateway or HTTPRoute
+type policyTarget struct {
+	kind string
+	key  types.NamespacedName
+}
+
+func (t policyTarget) String() string {
+	return t.kind + " " + t.key.String()
+}
+
+// Conflicts of policies by target. A policy may be attached to
+// multiple targets and conflict for some of them only, e.g. through
+// target selectors. Conflicts are recorded when targets are reconciled
+// such that policy status reflects conflicts for all targets
+var (
+	policyConflictsLock sync.Mutex
+	policyConflicts     = map[string]map[policyTarget]string{}
+)
+
+func policyKey(pol *attachedPolicy) string {
+	return pol.kind + " " + objectRef(pol.obj)
+}
+
+// Record conflicts of the policies attached to a target, replacing
+// conflicts previously recorded for the target. Returns the conflicts
+// of each of the policies across all targets
+func recordPolicyConflicts(target policyTarget, policies []*attachedPolicy) map[string]string {
+	targetConflicts := map[string][]string{}
+	for _, pol := range policies {
+		key := policyKey(pol)
+		if pol.conflict != "" && !slices.Contains(targetConflicts[key], pol.conflict) {
+			targetConflicts[key] = append(targetConflicts[key], pol.conflict)
+		} else if _, found := targetConflicts[key]; !found {
+			targetConflicts[key] = nil
+		}
+	}
+
+	policyConflictsLock.Lock()
+	defer policyConflictsLock.Unlock()
+	for key, targets := range policyConflicts {
+		if _, attached := targetConflicts[key]; !attached {
+			delete(targets, target)
+			if len(targets) == 0 {
+				delete(policyConflicts, key)
+			}
+		}
+	}
+	conflicts := map[string]string{}
+	for key, conflict := range targetConflicts {
+		targets := policyConflicts[key]
+		if len(conflict) > 0 {
+			if targets == nil {
+				targets = map[policyTarget]string{}
+				policyConflicts[key] = targets
+			}
+			targets[target] = strings.Join(conflict, "; ")
+		} else {
+			delete(targets, target)
+		}
+		if len(targets) == 0 {
+			delete(policyConflicts, key)
+			continue
+		}
+		messages := make([]string, 0, len(targets))
+		for t, msg := range targets {
+			messages = append(messages, t.String()+": "+msg)
+		}
+		sort.Strings(messages)
+		conflicts[key] = strings.Join(messages, "; ")
+	}
+	return conflicts
+}
+
+// Forget conflicts recorded for a target, e.g. when it is deleted
+func forgetPolicyTarget(target policyTarget) {
+	_ = recordPolicyConflicts(target, nil)
+}
+
+// Update the `Accepted` status condition of the policies attached to
+// a target, with reason `Conflicted` for policies with values masked
+// by other policies for this or other targets
+func updatePolicyStatus(ctx context.Context, r ControllerClient, target policyTarget, policies []*attachedPolicy) error {
+	conflicts := recordPolicyConflicts(target, policies)
+	updated := map[string]bool{}
+	for _, pol := range policies {
+		key := policyKey(pol)
+		if updated[key] {
+			continue
+		}
+		updated[key] = true
+		base, ok := pol.obj.DeepCopyObject().(client.Object)
+		if !ok {
+			return fmt.Errorf("cannot copy %s %s", pol.kind, objectRef(pol.obj))
+		}
+		before := append([]metav1.Condition{}, *pol.conditions...)
+		condition := metav1.Condition{
+			Type:               string(gatewayapiv1a2.PolicyConditionAccepted),
+			Status:             metav1.ConditionTrue,
+			Reason:             string(gatewayapiv1a2.PolicyReasonAccepted),
+			ObservedGeneration: pol.obj.GetGeneration(),
+		}
+		if conflict := conflicts[key]; conflict != "" {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = string(gatewayapiv1a2.PolicyReasonConflicted)
+			condition.Message = conflict
+		}
+		meta.SetStatusCondition(pol.conditions, condition)
+		if equality.Semantic.DeepEqual(before, *pol.conditions) {
+			continue
+		}
+		if err := r.Client().Status().Patch(ctx, pol.obj, client.MergeFrom(base)); err != nil {
+			return fmt.Errorf("cannot update status of %s %s: %w", pol.kind, objectRef(pol.obj), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func helperGatewayConfig(name string, created time.Time, defaults, overrides string) *attachedPolicy {
+	gwc := &gwcapi.GatewayConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", CreationTimestamp: metav1.NewTime(created)},
+	}
+	if defaults != "" {
+		gwc.Spec.Default = &apiextensionsv1.JSON{Raw: []byte(defaults)}
+	}
+	if overrides != "" {
+		gwc.Spec.Override = &apiextensionsv1.JSON{Raw: []byte(overrides)}
+	}
+	return gatewayConfigPolicy(gwc)
+}
+
+func TestResolvePolicyConflicts(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	policies := []*attachedPolicy{
+		helperGatewayConfig("oldest", t0, `{"tags": {"team": "a"}}`, ""),
+		helperGatewayConfig("newest", t0.Add(2*time.Hour), `{"tags": {"team": "c"}, "replicas": 1}`, `{"region": "x"}`),
+		helperGatewayConfig("b-middle", t0.Add(time.Hour), "", `{"region": "y"}`),
+		helperGatewayConfig("a-middle", t0.Add(time.Hour), `{"replicas": 2}`, ""),
+	}
+
+	resolvePolicyConflicts(policies)
+
+	// Increasing precedence, i.e. newest first and ties in reverse alphabetical order
+	order := []string{}
+	for _, pol := range policies {
+		order = append(order, pol.obj.GetName())
+	}
+	if strings.Join(order, ",") != "newest,b-middle,a-middle,oldest" {
+		t.Fatalf("Policy order error, got %v", order)
+	}
+
+	newest, bMiddle, aMiddle, oldest := policies[0], policies[1], policies[2], policies[3]
+	if !strings.Contains(newest.conflict, "region by GatewayConfig ns/b-middle") ||
+		!strings.Contains(newest.conflict, "replicas by GatewayConfig ns/a-middle") ||
+		!strings.Contains(newest.conflict, "tags.team by GatewayConfig ns/oldest") {
+		t.Fatalf("Conflict error, got %q", newest.conflict)
+	}
+	if bMiddle.conflict != "" || aMiddle.conflict != "" || oldest.conflict != "" {
+		t.Fatalf("Unexpected conflicts, got %q, %q, %q", bMiddle.conflict, aMiddle.conflict, oldest.conflict)
+	}
+}
+
+func TestUpdatePolicyStatus(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := helperGatewayConfig("older", t0, `{"replicas": 1}`, "")
+	newer := helperGatewayConfig("newer", t0.Add(time.Hour), `{"replicas": 2}`, "")
+	gw1 := policyTarget{"Gateway", types.NamespacedName{Namespace: "ns", Name: "gw1"}}
+	gw2 := policyTarget{"Gateway", types.NamespacedName{Namespace: "ns", Name: "gw2"}}
+	defer forgetPolicyTarget(gw1)
+	defer forgetPolicyTarget(gw2)
+
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(older.obj, newer.obj).
+			WithStatusSubresource(older.obj, newer.obj).Build(),
+		scheme: scheme,
+	}
+	accepted := func() metav1.ConditionStatus {
+		var gwc gwcapi.GatewayConfig
+		if err := r.client.Get(context.Background(), client.ObjectKeyFromObject(newer.obj), &gwc); err != nil {
+			t.Fatalf("Cannot get policy: %v", err)
+		}
+		cond := meta.FindStatusCondition(gwc.Status.Conditions, string(gatewayapiv1a2.PolicyConditionAccepted))
+		if cond == nil {
+			t.Fatalf("Policy has no Accepted condition")
+		}
+		return cond.Status
+	}
+
+	// Policies conflict when both target gw1
+	policies := []*attachedPolicy{older, newer}
+	resolvePolicyConflicts(policies)
+	if err := updatePolicyStatus(context.Background(), r, gw1, policies); err != nil {
+		t.Fatalf("Cannot update policy status: %v", err)
+	}
+	if status := accepted(); status != metav1.ConditionFalse {
+		t.Fatalf("Got Accepted %v, expected conflicted", status)
+	}
+
+	// The newer policy is still conflicted for gw1 when gw2 without
+	// conflicts is reconciled
+	resolvePolicyConflicts([]*attachedPolicy{newer})
+	if err := updatePolicyStatus(context.Background(), r, gw2, []*attachedPolicy{newer}); err != nil {
+		t.Fatalf("Cannot update policy status: %v", err)
+	}
+	if status := accepted(); status != metav1.ConditionFalse {
+		t.Fatalf("Got Accepted %v, expected conflicted through other target", status)
+	}
+
+	// No longer conflicted when the older policy is detached from gw1
+	if err := updatePolicyStatus(context.Background(), r, gw1, []*attachedPolicy{newer}); err != nil {
+		t.Fatalf("Cannot update policy status: %v", err)
+	}
+	if status := accepted(); status != metav1.ConditionTrue {
+		t.Fatalf("Got Accepted %v, expected accepted", status)
+	}
+}
+
+func TestLookupClusterGatewayClassConfigs(t *testing.T) {
+	scheme := OfflineScheme()
+	objs := []client.Object{
+		&gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "all"}},
+		&gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "internet"},
+			Spec: gwcapi.ClusterGatewayClassConfigSpec{
+				GatewayClassSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "internet"}}}},
+		&gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "internal"},
+			Spec: gwcapi.ClusterGatewayClassConfigSpec{
+				GatewayClassSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "internal"}}}},
+		&gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+			Spec: gwcapi.ClusterGatewayClassConfigSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "staging"}}}}}},
+		&gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+			Spec: gwcapi.ClusterGatewayClassConfigSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "env", Operator: "Bogus"}}}}},
+	}
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		scheme: scheme,
+	}
+
+	gwcLabels := labels.Set{"tier": "internet"}
+	cases := []struct {
+		nsLabels labels.Set
+		expected string
+	}{
+		{labels.Set{"env": "prod"}, "all,internet,prod"},
+		{labels.Set{}, "all,internet"},
+	}
+	for _, tc := range cases {
+		policies, err := lookupClusterGatewayClassConfigs(context.Background(), r, gwcLabels, tc.nsLabels)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		names := []string{}
+		for _, pol := range policies {
+			names = append(names, pol.obj.GetName())
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != tc.expected {
+			t.Fatalf("Namespace labels %v: expected %q, got %v", tc.nsLabels, tc.expected, names)
+		}
+	}
+}
+
+func TestPolicyTargets(t *testing.T) {
+	ns := gatewayapiv1a2.Namespace("foo")
+	policy := &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"}}
+	gwLabels := labels.Set{"team": "a"}
+	selector := func(kind string, matchLabels map[string]string) *gwcapi.PolicyTargetSelector {
+		return &gwcapi.PolicyTargetSelector{Group: gatewayapi.GroupName, Kind: gatewayapiv1a2.Kind(kind),
+			Selector: metav1.LabelSelector{MatchLabels: matchLabels}}
+	}
+
+	cases := []struct {
+		name     string
+		ref      *gatewayapiv1a2.NamespacedPolicyTargetReference
+		sel      *gwcapi.PolicyTargetSelector
+		expected bool
+	}{
+		{"ref", &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"}, nil, true},
+		{"ref-namespace", &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw", Namespace: &ns}, nil, true},
+		{"ref-other-name", &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "other"}, nil, false},
+		{"ref-other-kind", &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Name: "gw"}, nil, false},
+		{"selector", nil, selector("Gateway", map[string]string{"team": "a"}), true},
+		{"selector-empty", nil, selector("Gateway", nil), true},
+		{"selector-other-labels", nil, selector("Gateway", map[string]string{"team": "b"}), false},
+		{"selector-other-kind", nil, selector("HTTPRoute", map[string]string{"team": "a"}), false},
+		{"none", nil, nil, false},
+	}
+	for _, tc := range cases {
+		if policyTargets(context.Background(), policy, tc.ref, tc.sel, gatewayapi.GroupName, "Gateway", "foo", "gw", gwLabels) != tc.expected {
+			t.Errorf("Case %s: expected %v", tc.name, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// A HTTPRoute attached to the parent Gateway, see TemplateValues
+type TemplateHTTPRouteValues struct {
+	// The HTTPRoute
+	HTTPRoute map[string]any
+
+	// Names of the Gateway listeners the HTTPRoute is attached to
+	Listeners []string
+}
+
+// HTTPRoutes attached to at least one listener of a Gateway, ordered
+// by namespace and name, for use in Gateway templates
+func attachedHTTPRoutes(ctx context.Context, r ControllerClient, gw *gatewayapi.Gateway,
+	rtList []*gatewayapi.HTTPRoute) ([]TemplateHTTPRouteValues, error) {
+	rtList = uniqueHTTPRoutes(filterHTTPRoutesForGateway(gw, rtList))
+	slices.SortStableFunc(rtList, func(a, b *gatewayapi.HTTPRoute) int {
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+
+	routes := make([]TemplateHTTPRouteValues, 0, len(rtList))
+	for _, rt := range rtList {
+		nsLabels, err := lookupLabels(ctx, r, &corev1.Namespace{}, types.NamespacedName{Name: rt.Namespace})
+		if err != nil {
+			return nil, err
+		}
+		listeners := attachedListeners(gw, rt, nsLabels)
+		if len(listeners) == 0 {
+			continue
+		}
+		rtMap, err := parentToMap(rt)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, TemplateHTTPRouteValues{rtMap, listeners})
+	}
+	return routes, nil
+}
+
+// Names of the listeners of a Gateway a HTTPRoute is attached to,
+// i.e. listeners matching the sectionName and port of a parentRef
+// referencing the Gateway, which allow HTTPRoutes from the namespace
+// of the HTTPRoute and with a hostname intersecting the hostnames of
+// the HTTPRoute
+func attachedListeners(gw *gatewayapi.Gateway, rt *gatewayapi.HTTPRoute, rtNsLabels labels.Set) []string {
+	var names []string
+	for idx := range gw.Spec.Listeners {
+		l := &gw.Spec.Listeners[idx]
+		if slices.Contains(names, string(l.Name)) || !listenerAllowsHTTPRoute(gw, l, rt, rtNsLabels) ||
+			!listenerHostnameMatches(l, rt) {
+			continue
+		}
+		for pIdx := range rt.Spec.ParentRefs {
+			pRef := &rt.Spec.ParentRefs[pIdx]
+			if parentRefIsGateway(gw, rt, pRef) &&
+				(pRef.SectionName == nil || *pRef.SectionName == l.Name) &&
+				(pRef.Port == nil || *pRef.Port == l.Port) {
+				names = append(names, string(l.Name))
+				break
+			}
+		}
+	}
+	return names
+}
+
+// Whether a listener allows HTTPRoutes from the namespace of a
+// HTTPRoute. Without allowed kinds, HTTP and HTTPS listeners allow
+// HTTPRoutes. Without allowed namespaces, HTTPRoutes must be in the
+// namespace of the Gateway
+func listenerAllowsHTTPRoute(gw *gatewayapi.Gateway, l *gatewayapi.Listener, rt *gatewayapi.HTTPRoute, rtNsLabels labels.Set) bool {
+	allowed := l.AllowedRoutes
+	if allowed == nil || len(allowed.Kinds) == 0 {
+		if l.Protocol != gatewayapi.HTTPProtocolType && l.Protocol != gatewayapi.HTTPSProtocolType {
+			return false
+		}
+	} else if !slices.ContainsFunc(allowed.Kinds, func(kind gatewayapi.RouteGroupKind) bool {
+		return kind.Kind == "HTTPRoute" && (kind.Group == nil || *kind.Group == gatewayapi.GroupName)
+	}) {
+		return false
+	}
+
+	from := gatewayapi.NamespacesFromSame
+	if allowed != nil && allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+		from = *allowed.Namespaces.From
+	}
+	switch from {
+	case gatewayapi.NamespacesFromAll:
+		return true
+	case gatewayapi.NamespacesFromSelector:
+		if allowed.Namespaces.Selector == nil {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		return err == nil && selector.Matches(rtNsLabels)
+	default:
+		return rt.Namespace == gw.Namespace
+	}
+}
+
+// Whether the hostname of a listener intersects the hostnames of a
+// HTTPRoute. Listeners and HTTPRoutes without hostnames match all
+// hostnames
+func listenerHostnameMatches(l *gatewayapi.Listener, rt *gatewayapi.HTTPRoute) bool {
+	if l.Hostname == nil || len(rt.Spec.Hostnames) == 0 {
+		return true
+	}
+	for _, hostname := range rt.Spec.Hostnames {
+		if hostnamesIntersect(string(*l.Hostname), string(hostname)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether two hostnames, possibly with a '*.' wildcard prefix, match a
+// common hostname
+func hostnamesIntersect(a, b string) bool {
+	return a == b ||
+		(strings.HasPrefix(a, "*.") && strings.HasSuffix(b, a[1:])) ||
+		(strings.HasPrefix(b, "*.") && strings.HasSuffix(a, b[1:]))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestAttachedListeners(t *testing.T) {
+	hostname := func(h string) *gatewayapi.Hostname { hn := gatewayapi.Hostname(h); return &hn }
+	fromAll := gatewayapi.NamespacesFromAll
+	fromSelector := gatewayapi.NamespacesFromSelector
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "foo"},
+		Spec: gatewayapi.GatewaySpec{Listeners: []gatewayapi.Listener{
+			{Name: "http", Port: 80, Protocol: gatewayapi.HTTPProtocolType, Hostname: hostname("*.example.com")},
+			{Name: "https", Port: 443, Protocol: gatewayapi.HTTPSProtocolType, Hostname: hostname("foo.example.com"),
+				AllowedRoutes: &gatewayapi.AllowedRoutes{Namespaces: &gatewayapi.RouteNamespaces{From: &fromAll}}},
+			{Name: "internal", Port: 8080, Protocol: gatewayapi.HTTPProtocolType,
+				AllowedRoutes: &gatewayapi.AllowedRoutes{Namespaces: &gatewayapi.RouteNamespaces{From: &fromSelector,
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"internal": "true"}}}}},
+			{Name: "tcp", Port: 9000, Protocol: gatewayapi.TCPProtocolType},
+		}},
+	}
+	route := func(namespace string, hostnames []gatewayapi.Hostname, ref gatewayapi.ParentReference) *gatewayapi.HTTPRoute {
+		ref.Name = "gw"
+		if namespace != "foo" {
+			ns := gatewayapi.Namespace("foo")
+			ref.Namespace = &ns
+		}
+		return &gatewayapi.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: namespace},
+			Spec: gatewayapi.HTTPRouteSpec{Hostnames: hostnames,
+				CommonRouteSpec: gatewayapi.CommonRouteSpec{ParentRefs: []gatewayapi.ParentReference{ref}}},
+		}
+	}
+	section := gatewayapi.SectionName("https")
+	port := gatewayapi.PortNumber(80)
+
+	cases := []struct {
+		name     string
+		rt       *gatewayapi.HTTPRoute
+		nsLabels labels.Set
+		expected []string
+	}{
+		{"all listeners", route("foo", nil, gatewayapi.ParentReference{}), nil, []string{"http", "https"}},
+		{"all listeners selected namespace", route("foo", nil, gatewayapi.ParentReference{}), labels.Set{"internal": "true"}, []string{"http", "https", "internal"}},
+		{"hostname", route("foo", []gatewayapi.Hostname{"bar.example.com"}, gatewayapi.ParentReference{}), nil, []string{"http"}},
+		{"other namespace", route("bar", nil, gatewayapi.ParentReference{}), nil, []string{"https"}},
+		{"namespace selector", route("bar", nil, gatewayapi.ParentReference{}), labels.Set{"internal": "true"}, []string{"https", "internal"}},
+		{"section name", route("foo", nil, gatewayapi.ParentReference{SectionName: &section}), nil, []string{"https"}},
+		{"section name hostname mismatch", route("foo", []gatewayapi.Hostname{"bar.example.com"},
+			gatewayapi.ParentReference{SectionName: &section}), nil, nil},
+		{"port", route("foo", nil, gatewayapi.ParentReference{Port: &port}), nil, []string{"http"}},
+		{"other gateway", &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: "foo"},
+			Spec: gatewayapi.HTTPRouteSpec{CommonRouteSpec: gatewayapi.CommonRouteSpec{
+				ParentRefs: []gatewayapi.ParentReference{{Name: "other"}}}}}, nil, nil},
+	}
+	for _, tc := range cases {
+		if listeners := attachedListeners(gw, tc.rt, tc.nsLabels); !reflect.DeepEqual(listeners, tc.expected) {
+			t.Errorf("%s: got listeners %v, expected %v", tc.name, listeners, tc.expected)
+		}
+	}
+}
+
+func TestHostnamesIntersect(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"foo.example.com", "foo.example.com", true},
+		{"*.example.com", "foo.example.com", true},
+		{"foo.example.com", "*.example.com", true},
+		{"*.example.com", "*.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "foo.example.org", false},
+		{"foo.example.com", "bar.example.com", false},
+	}
+	for _, tc := range cases {
+		if hostnamesIntersect(tc.a, tc.b) != tc.expected {
+			t.Errorf("hostnamesIntersect(%q, %q), expected %v", tc.a, tc.b, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Directory of blueprint files read by 'file' sources, e.g. from the
+// --blueprint-source-dir argument. File sources are disabled if empty
+var BlueprintSourceDir string
+
+// Client used to pull OCI artifacts from registries
+var ociHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Maximum size of OCI manifests and blueprint layers
+const maxBlueprintSourceSize = 4 << 20
+
+// Media types of OCI manifests accepted when pulling blueprints
+const ociManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+// Retry periods after failing to read a source. The period doubles
+// with each failure
+const (
+	sourceRetryMinPeriod = 10 * time.Second
+	sourceRetryMaxPeriod = 10 * time.Minute
+)
+
+// Reads blueprint sources, returning the spec and revision read
+type blueprintSourceReader func(context.Context, *gwcapi.BlueprintSource) (*gwcapi.GatewayClassBlueprintSpec, string, error)
+
+// The latest result of reading a source
+type blueprintSourceEntry struct {
+	// Spec and revision of the latest successful read, nil if the
+	// source was never read
+	spec     *gwcapi.GatewayClassBlueprintSpec
+	revision string
+
+	// Error from the latest read, if it failed, and when to retry
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+// Blueprints read from sources. Sources are only read when
+// GatewayClasses are reconciled, see resolveBlueprintSource, while
+// Gateways and HTTPRoutes use the blueprints read, see
+// cachedBlueprintSource
+var blueprintSourceCache = struct {
+	sync.Mutex
+	entries map[string]*blueprintSourceEntry
+}{entries: map[string]*blueprintSourceEntry{}}
+
+// Cache key of a source. Sources pinned by digest or commit are
+// immutable, i.e. they are only read once
+func blueprintSourceKey(src *gwcapi.BlueprintSource) (key string, immutable bool) {
+	switch {
+	case src.OCI != "":
+		return "oci:" + src.OCI, true
+	case src.Git != nil:
+		return fmt.Sprintf("git:%s@%s:%s", src.Git.Repository, src.Git.Commit, src.Git.Path), true
+	default:
+		return "file:" + src.File, false
+	}
+}
+
+// Read a source and cache the result. Immutable sources already read
+// are not read again. After a failure, the source is not read again
+// until the retry period has passed, and the error is returned
+func resolveBlueprintSource(ctx context.Context, src *gwcapi.BlueprintSource) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	key, immutable := blueprintSourceKey(src)
+	blueprintSourceCache.Lock()
+	entry, found := blueprintSourceCache.entries[key]
+	if !found {
+		entry = &blueprintSourceEntry{}
+		blueprintSourceCache.entries[key] = entry
+	}
+	switch {
+	case entry.err != nil && time.Now().Before(entry.retryAt):
+		err := entry.err
+		blueprintSourceCache.Unlock()
+		return nil, "", err
+	case entry.err == nil && entry.spec != nil && immutable:
+		spec, revision := entry.spec.DeepCopy(), entry.revision
+		blueprintSourceCache.Unlock()
+		return spec, revision, nil
+	}
+	blueprintSourceCache.Unlock()
+
+	spec, revision, err := readBlueprintSource(ctx, src)
+
+	blueprintSourceCache.Lock()
+	defer blueprintSourceCache.Unlock()
+	if err != nil {
+		entry.err = err
+		entry.failures++
+		entry.retryAt = time.Now().Add(sourceRetryPeriod(entry.failures))
+		return nil, "", err
+	}
+	entry.spec, entry.revision = spec, revision
+	entry.err, entry.failures = nil, 0
+	return spec.DeepCopy(), revision, nil
+}
+
+// Retry period after a number of consecutive failures
+func sourceRetryPeriod(failures int) time.Duration {
+	period := sourceRetryMinPeriod
+	for i := 1; i < failures && period < sourceRetryMaxPeriod; i++ {
+		period *= 2
+	}
+	return min(period, sourceRetryMaxPeriod)
+}
+
+// The blueprint last read from a source by resolveBlueprintSource,
+// without reading the source
+func cachedBlueprintSource(_ context.Context, src *gwcapi.BlueprintSource) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	key, _ := blueprintSourceKey(src)
+	blueprintSourceCache.Lock()
+	defer blueprintSourceCache.Unlock()
+	entry, found := blueprintSourceCache.entries[key]
+	switch {
+	case found && entry.spec != nil:
+		return entry.spec.DeepCopy(), entry.revision, nil
+	case found && entry.err != nil:
+		return nil, "", entry.err
+	default:
+		return nil, "", errors.New("source not read yet")
+	}
+}
+
+// Read the blueprint of a source, returning its spec and revision
+func readBlueprintSource(ctx context.Context, src *gwcapi.BlueprintSource) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	set := 0
+	for _, isSet := range []bool{src.OCI != "", src.Git != nil, src.File != ""} {
+		if isSet {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, "", errors.New("source must have only one of 'oci', 'git' or 'file'")
+	case src.OCI != "":
+		return pullOCIBlueprint(ctx, src.OCI)
+	case src.Git != nil:
+		return readGitBlueprint(ctx, src.Git)
+	case src.File != "":
+		return readFileBlueprint(src.File)
+	default:
+		return nil, "", errors.New("source without 'oci', 'git' or 'file'")
+	}
+}
+
+// Read a blueprint from a file below BlueprintSourceDir. The revision
+// is the digest of the file
+func readFileBlueprint(name string) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	if BlueprintSourceDir == "" {
+		return nil, "", errors.New("file sources are disabled, see --blueprint-source-dir")
+	}
+	if !filepath.IsLocal(name) {
+		return nil, "", fmt.Errorf("file %q is not below the blueprint source directory", name)
+	}
+	data, err := os.ReadFile(filepath.Join(BlueprintSourceDir, name))
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	spec, err := decodeSourceBlueprint(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("file %q: %w", name, err)
+	}
+	return spec, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Pull a blueprint from an OCI artifact pinned by digest, i.e.
+// 'registry/repository@sha256:...'. Artifacts must have a single
+// layer holding the blueprint. Anonymous access and bearer tokens
+// issued anonymously by the registry are supported
+func pullOCIBlueprint(ctx context.Context, ref string) (*gwcapi.GatewayClassBlueprintSpec, string, error) {
+	name, digest, found := strings.Cut(ref, "@")
+	if !found || !strings.HasPrefix(digest, "sha256:") {
+		return nil, "", fmt.Errorf("oci reference %q is not pinned by a sha256 digest", ref)
+	}
+	registry, repository, found := strings.Cut(name, "/")
+	if !found {
+		return nil, "", fmt.Errorf("oci reference %q has no registry", ref)
+	}
+	if idx := strings.LastIndex(repository, ":"); idx >= 0 {
+		repository = repository[:idx] // Tag is ignored, the digest is authoritative
+	}
+
+	base := fmt.Sprintf("https://%s/v2/%s", registry, repository)
+	manifestData, err := fetchOCIBlob(ctx, base+"/manifests/"+digest, ociManifestMediaTypes, digest)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot pull manifest of %q: %w", ref, err)
+	}
+	var manifest struct {
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, "", fmt.Errorf("cannot decode manifest of %q: %w", ref, err)
+	}
+	if len(manifest.Layers) != 1 {
+		return nil, "", fmt.Errorf("oci artifact %q has %d layers, expected 1", ref, len(manifest.Layers))
+	}
+	layerData, err := fetchOCIBlob(ctx, base+"/blobs/"+manifest.Layers[0].Digest, "*/*", manifest.Layers[0].Digest)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot pull layer of %q: %w", ref, err)
+	}
+	spec, err := decodeSourceBlueprint(layerData)
+	if err != nil {
+		return nil, "", fmt.Errorf("oci artifact %q: %w", ref, err)
+	}
+	return spec, digest, nil
+}
+
+// Fetch a manifest or blob from a registry and verify its digest. A
+// bearer token is requested if the registry asks for one
+func fetchOCIBlob(ctx context.Context, blobURL, accept, digest string) ([]byte, error) {
+	resp, err := ociGet(ctx, blobURL, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		registry, err := url.Parse(blobURL)
+		if err != nil {
+			return nil, err
+		}
+		token, err := ociToken(ctx, registry.Hostname(), challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = ociGet(ctx, blobURL, accept, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlueprintSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBlueprintSourceSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxBlueprintSourceSize)
+	}
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("digest mismatch, expected %s", digest)
+	}
+	return data, nil
+}
+
+func ociGet(ctx context.Context, getURL, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ociHTTPClient.Do(req)
+}
+
+// Request an anonymous bearer token as described by a
+// 'WWW-Authenticate: Bearer realm="...",service="...",scope="..."'
+// challenge from a registry. The realm must use https and be on the
+// registry host or a host in the same registered domain, e.g.
+// 'auth.docker.io' for 'registry-1.docker.io', such that registries
+// cannot direct the controller to request arbitrary URLs
+func ociToken(ctx context.Context, registry, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication %q", scheme)
+	}
+	var realm string
+	query := url.Values{}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		if key == "realm" {
+			realm = value
+		} else if key == "service" || key == "scope" {
+			query.Set(key, value)
+		}
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil || realm == "" {
+		return "", fmt.Errorf("authentication challenge with invalid realm %q", realm)
+	}
+	if !ociRealmAllowed(registry, tokenURL) {
+		return "", fmt.Errorf("authentication realm %q is not an https URL on the domain of registry %q", realm, registry)
+	}
+	tokenQuery := tokenURL.Query()
+	for key := range query {
+		tokenQuery.Set(key, query.Get(key))
+	}
+	tokenURL.RawQuery = tokenQuery.Encode()
+	resp, err := ociGet(ctx, tokenURL.String(), "application/json", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get token: unexpected status %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBlueprintSourceSize)).Decode(&token); err != nil {
+		return "", fmt.Errorf("cannot decode token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// Whether a registry may direct token requests to a realm, see ociToken
+func ociRealmAllowed(registry string, realm *url.URL) bool {
+	if realm.Scheme != "https" {
+		return false
+	}
+	if realm.Hostname() == registry {
+		return true
+	}
+	registryDomain, err := publicsuffix.EffectiveTLDPlusOne(registry)
+	if err != nil {
+		return false
+	}
+	realmDomain, err := publicsuffix.EffectiveTLDPlusOne(realm.Hostname())
+	return err == nil && realmDomain == registryDomain
+}
+
+// Decode a GatewayClassBlueprint read from a source. Blueprints read
+// from sources cannot themselves have a source or base blueprint
+func decodeSourceBlueprint(data []byte) (*gwcapi.GatewayClassBlueprintSpec, error) {
+	var gwcb gwcapi.GatewayClassBlueprint
+	if err := sigsyaml.UnmarshalStrict(data, &gwcb); err != nil {
+		return nil, fmt.Errorf("cannot decode blueprint: %w", err)
+	}
+	if gwcb.Kind != "GatewayClassBlueprint" {
+		return nil, fmt.Errorf("expected GatewayClassBlueprint, got %q", gwcb.Kind)
+	}
+	if gwcb.Spec.Source != nil || gwcb.Spec.BaseBlueprint != "" {
+		return nil, errors.New("blueprints read from a source cannot have a source or base blueprint")
+	}
+	return &gwcb.Spec, nil
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+var sourceBlueprint = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: shared
+spec:
+  gatewayTemplate:
+    resourceTemplates:
+      lb: source-lb
+      dns: source-dns
+`
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestBlueprintSources(t *testing.T) {
+	scheme := OfflineScheme()
+	r := &offlineClient{client: fake.NewClientBuilder().WithScheme(scheme).Build(), scheme: scheme}
+
+	savedCache := blueprintSourceCache.entries
+	blueprintSourceCache.entries = map[string]*blueprintSourceEntry{}
+	defer func() { blueprintSourceCache.entries = savedCache }()
+
+	// File source
+	savedDir := BlueprintSourceDir
+	BlueprintSourceDir = t.TempDir()
+	defer func() { BlueprintSourceDir = savedDir }()
+	if err := os.WriteFile(filepath.Join(BlueprintSourceDir, "shared.yaml"), []byte(sourceBlueprint), 0o600); err != nil {
+		t.Fatalf("Cannot write blueprint: %v", err)
+	}
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "gwcb"}}
+	gwcb.Spec.Source = &gwcapi.BlueprintSource{File: "shared.yaml"}
+	gwcb.Spec.GatewayTemplate.ResourceTemplates = map[string]string{"dns": ""}
+	resolved, err := resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource)
+	if err != nil {
+		t.Fatalf("Cannot resolve blueprint: %v", err)
+	}
+	if templates := resolved.Spec.GatewayTemplate.ResourceTemplates; len(templates) != 1 || templates["lb"] != "source-lb" {
+		t.Errorf("Got templates %v, expected lb from source", templates)
+	}
+	if resolved.Status.SourceRevision != sha256Digest([]byte(sourceBlueprint)) {
+		t.Errorf("Got revision %q, expected digest of file", resolved.Status.SourceRevision)
+	}
+	gwcb.Spec.Source.File = "../shared.yaml"
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource); err == nil {
+		t.Errorf("Expected error reading file outside source directory")
+	}
+
+	// OCI source served by a registry requiring anonymous bearer tokens
+	layer := []byte(sourceBlueprint)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"layers":[{"digest":%q,"size":%d}]}`, sha256Digest(layer), len(layer)))
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if req.URL.Query().Get("scope") != "repository:blueprints:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="test",scope="repository:blueprints:pull"`, req.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests++
+		switch req.URL.Path {
+		case "/v2/blueprints/manifests/" + sha256Digest(manifest):
+			_, _ = w.Write(manifest)
+		case "/v2/blueprints/blobs/" + sha256Digest(layer):
+			_, _ = w.Write(layer)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	savedClient := ociHTTPClient
+	ociHTTPClient = srv.Client()
+	defer func() { ociHTTPClient = savedClient }()
+
+	ref := srv.Listener.Addr().String() + "/blueprints:v1@" + sha256Digest(manifest)
+	gwcb.Spec.Source = &gwcapi.BlueprintSource{OCI: ref}
+	for range 2 {
+		resolved, err = resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource)
+		if err != nil {
+			t.Fatalf("Cannot resolve blueprint: %v", err)
+		}
+		if resolved.Spec.GatewayTemplate.ResourceTemplates["lb"] != "source-lb" || resolved.Status.SourceRevision != sha256Digest(manifest) {
+			t.Errorf("Got templates %v and revision %q, expected lb and digest of manifest",
+				resolved.Spec.GatewayTemplate.ResourceTemplates, resolved.Status.SourceRevision)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Got %d requests, expected 2 since artifact is cached", requests)
+	}
+	if resolved, err = resolveBlueprint(context.Background(), r, gwcb, cachedBlueprintSource); err != nil ||
+		resolved.Status.SourceRevision != sha256Digest(manifest) {
+		t.Errorf("Got revision %q and error %v, expected cached digest of manifest", resolved.Status.SourceRevision, err)
+	}
+
+	// Unknown digest, which is not retried until the retry period has passed
+	gwcb.Spec.Source.OCI = srv.Listener.Addr().String() + "/blueprints@" + sha256Digest([]byte("other"))
+	requests = 0
+	for range 2 {
+		if _, err := resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource); err == nil {
+			t.Errorf("Expected error for unknown digest")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Got %d requests, expected 1 since failures are retried later", requests)
+	}
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, cachedBlueprintSource); err == nil {
+		t.Errorf("Expected cached error for unknown digest")
+	}
+	gwcb.Spec.Source.OCI = srv.Listener.Addr().String() + "/blueprints@" + sha256Digest([]byte("unread"))
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, cachedBlueprintSource); err == nil ||
+		!strings.Contains(err.Error(), "not read yet") {
+		t.Errorf("Got error %v, expected source not read yet", err)
+	}
+	gwcb.Spec.Source.OCI = srv.Listener.Addr().String() + "/blueprints:v1"
+	if _, err := resolveBlueprint(context.Background(), r, gwcb, resolveBlueprintSource); err == nil || !strings.Contains(err.Error(), "not pinned") {
+		t.Errorf("Got error %v, expected reference not pinned by digest", err)
+	}
+}
+
+func TestFetchOCIBlobDigest(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+	savedClient := ociHTTPClient
+	ociHTTPClient = srv.Client()
+	defer func() { ociHTTPClient = savedClient }()
+
+	_, err := fetchOCIBlob(context.Background(), srv.URL+"/v2/blueprints/blobs/x", "*/*", sha256Digest([]byte("original")))
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Got error %v, expected digest mismatch", err)
+	}
+}
+
+func TestOCIRealmAllowed(t *testing.T) {
+	cases := []struct {
+		registry, realm string
+		expected        bool
+	}{
+		{"registry-1.docker.io", "https://auth.docker.io/token", true},
+		{"ghcr.io", "https://ghcr.io/token", true},
+		{"127.0.0.1", "https://127.0.0.1:5000/token", true},
+		{"ghcr.io", "http://ghcr.io/token", false},
+		{"ghcr.io", "https://example.com/token", false},
+		{"foo.github.io", "https://bar.github.io/token", false}, // github.io is a public suffix
+		{"127.0.0.1", "https://169.254.169.254/latest", false},
+	}
+	for _, tc := range cases {
+		realm, err := url.Parse(tc.realm)
+		if err != nil {
+			t.Fatalf("Cannot parse %q: %v", tc.realm, err)
+		}
+		if allowed := ociRealmAllowed(tc.registry, realm); allowed != tc.expected {
+			t.Errorf("Registry %s realm %s: got allowed %v, expected %v", tc.registry, tc.realm, allowed, tc.expected)
+		}
+	}
+}
+
+func TestSourceRetryPeriod(t *testing.T) {
+	cases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, sourceRetryMinPeriod},
+		{2, 2 * sourceRetryMinPeriod},
+		{4, 8 * sourceRetryMinPeriod},
+		{100, sourceRetryMaxPeriod},
+	}
+	for _, tc := range cases {
+		if period := sourceRetryPeriod(tc.failures); period != tc.expected {
+			t.Errorf("%d failures: got %v, expected %v", tc.failures, period, tc.expected)
+		}
+	}
+}
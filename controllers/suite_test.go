@@ -52,6 +52,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	gateway "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -92,6 +93,9 @@ var _ = BeforeSuite(func() {
 	err = gateway.Install(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = gatewayv1b1.Install(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = gcapi.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -108,6 +112,9 @@ var _ = BeforeSuite(func() {
 		Cache: cache.Options{
 			SyncPeriod: &syncPeriod,
 		},
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: UncachedObjects()},
+		},
 	})
 	Expect(err).ToNot(HaveOccurred())
 
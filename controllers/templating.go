@@ -39,17 +39,24 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 )
 
 // Information about a resource, rendered format as well as actual in API server
@@ -65,6 +72,12 @@ type ResourceComposite struct {
 
 	// Whether resource is namespaced or not
 	IsNamespaced bool
+
+	// Error from latest attempt to apply the rendered resource, nil if successful
+	ApplyErr error
+
+	// Field-level changes found by a dry-run apply, see diffResource
+	Diff []string
 }
 
 // Rendering and applying templates is a multi-stage process. This
@@ -76,11 +89,17 @@ type ResourceTemplateState struct {
 	// Name of template (from template key in GatewayClassBlueprint, not Kubernetes resource name)
 	TemplateName string
 
+	// Name of GatewayClass the template belongs to
+	GatewayClassName string
+
 	// Raw template
 	StringTemplate string
 
 	// Resource information, rendered and current
 	Resources []ResourceComposite
+
+	// Error from latest attempt to render template, nil if successful
+	RenderErr error
 }
 
 // Parameters used when rendering templates
@@ -91,6 +110,30 @@ type TemplateValues struct {
 	// Parent HTTPRoute. Only set when rendering HTTPRoute templates
 	HTTPRoute map[string]any
 
+	// HTTPRoutes attached to the parent Gateway, together with the
+	// listeners they are attached to. Only set when rendering
+	// Gateway templates
+	HTTPRoutes []TemplateHTTPRouteValues
+
+	// Backends of the parent HTTPRoute resolved to Services, in
+	// the order of rules and backendRefs. Only set when rendering
+	// HTTPRoute templates
+	Backends []TemplateBackendValues
+
+	// Namespace of the parent resource, i.e. the HTTPRoute when
+	// rendering HTTPRoute templates and otherwise the Gateway
+	Namespace map[string]any
+
+	// Namespace of the parent Gateway, always defined
+	GatewayNamespace map[string]any
+
+	// GatewayClass of the parent Gateway, always defined
+	GatewayClass map[string]any
+
+	// GatewayClassBlueprint holding the templates, with metadata
+	// only. Always defined
+	Blueprint map[string]any
+
 	// Template values
 	Values map[string]any
 
@@ -101,6 +144,13 @@ type TemplateValues struct {
 	// HTTPRoutes. These lists of hostnames are particularly
 	// useful for TLS certificates which are not port specific.
 	Hostnames TemplateHostnameValues
+
+	// Paths of values from Secrets, redacted in logs in addition
+	// to RedactedValuePaths
+	sensitivePaths []string
+
+	// Implementation of the 'lookup' template function, may be nil
+	lookup *templateLookup
 }
 
 type TemplateHostnameValues struct {
@@ -111,16 +161,23 @@ type TemplateHostnameValues struct {
 	Union, Intersection []string
 }
 
-// Parse a single template with our additional functions added
-func parseSingleTemplate(tmplKey, tmpl string) (*template.Template, error) {
-	var funcs = template.FuncMap{
-		"toYaml": helperToYaml,
+// Parse a single template with our additional functions added, see
+// helperFuncs. Named templates from helpers (the 'helpers' of the
+// GatewayClassBlueprint) are associated with the template, and may be
+// redefined by the template
+func parseSingleTemplate(tmplKey, helpers, tmpl string) (*template.Template, error) {
+	t := template.New(tmplKey).Option("missingkey=error").Funcs(sprig.TxtFuncMap())
+	t = t.Funcs(helperFuncs(t))
+	if helpers != "" {
+		if _, err := t.New("helpers").Parse(helpers); err != nil {
+			return nil, fmt.Errorf("cannot parse helpers: %w", err)
+		}
 	}
-	return template.New(tmplKey).Option("missingkey=error").Funcs(sprig.TxtFuncMap()).Funcs(funcs).Parse(tmpl)
+	return t.Parse(tmpl)
 }
 
 // Initialize ResourceTemplateState slice by parsing templates
-func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateState, error) {
+func parseTemplates(gatewayClassName, helpers string, resourceTemplates map[string]string) ([]*ResourceTemplateState, error) {
 	var err error
 
 	templates := make([]*ResourceTemplateState, 0, len(resourceTemplates))
@@ -128,10 +185,11 @@ func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateSta
 	for tmplKey, tmpl := range resourceTemplates {
 		r := ResourceTemplateState{}
 		r.TemplateName = tmplKey
+		r.GatewayClassName = gatewayClassName
 		r.StringTemplate = tmpl
-		r.Template, err = parseSingleTemplate(tmplKey, tmpl)
+		r.Template, err = parseSingleTemplate(tmplKey, helpers, tmpl)
 		if err != nil {
-			metricTemplateParseErrs.Inc()
+			metricTemplateParseErrs.WithLabelValues(gatewayClassName, tmplKey).Inc()
 			return nil, fmt.Errorf("cannot parse template %q: %w", tmplKey, err)
 		}
 		r.Resources = make([]ResourceComposite, 0)
@@ -149,27 +207,39 @@ func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateSta
 // fetching current resource from API server/cache require that we can
 // render the template first. Rendering errors on final attempt are
 // logged as errors.
-func renderTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Object,
+func renderTemplates(ctx context.Context, r ControllerDynClient, parent client.Object,
 	templates []*ResourceTemplateState, values *TemplateValues, isFinalAttempt bool) (rendered, exists int) {
 	var err error
 
 	logger := log.FromContext(ctx)
+	debugLog := debugLogger(ctx, parent)
 	ns := parent.GetNamespace()
 
 	for tIdx := range templates {
 		tmpl := templates[tIdx]
 		if len(tmpl.Resources) == 0 {
+			_, span := tracer.Start(ctx, "renderTemplate", trace.WithAttributes(attrTemplate.String(tmpl.TemplateName)))
+			start := time.Now()
 			tmpl.Resources, err = template2Composite(r, tmpl.Template, values)
+			metricTemplateRenderDuration.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Observe(time.Since(start).Seconds())
+			tmpl.RenderErr = err
+			spanError(span, err)
+			span.End()
 			if err != nil {
 				if isFinalAttempt {
 					logger.Error(err, "cannot render template", "templateName", tmpl.TemplateName)
-					// FIXME: These are convenient, but we should have a better logging design, i.e. it should be possible to enable rendering errors only
-					fmt.Printf("Template:\n%s\n", tmpl.StringTemplate)
-					fmt.Printf("Template values:\n%+v\n", values)
-					metricTemplateErrs.Inc()
+					r.Recorder().Eventf(parent, corev1.EventTypeWarning, EventReasonRenderError,
+						"cannot render template %q: %v", tmpl.TemplateName, err)
+					debugLog.Info("template render error", "templateName", tmpl.TemplateName,
+						"template", tmpl.StringTemplate, "values", redactTemplateValues(values))
+					metricTemplateErrs.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Inc()
 				}
 				continue
 			}
+			if debugLog.Enabled() {
+				debugLog.Info("rendered template", "templateName", tmpl.TemplateName,
+					"rendered", redactText(compositesToYaml(tmpl.Resources), values.Values, values.redactedPaths()))
+			}
 		}
 		rendered++
 		for resIdx := range tmpl.Resources {
@@ -181,15 +251,17 @@ func renderTemplates(ctx context.Context, r ControllerDynClient, parent metav1.O
 				} else {
 					dynamicClient = r.DynamicClient().Resource(*res.GVR)
 				}
-				metricResourceGet.Inc()
+				metricResourceGet.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Inc()
 				res.Current, err = dynamicClient.Get(ctx, res.Rendered.GetName(), metav1.GetOptions{})
 				if err != nil {
 					logger.Error(err, "cannot get current resource", "templateName", tmpl.TemplateName, "resIdx", resIdx)
 					continue
 				}
-				logger.Info("update current", "templatename", tmpl.TemplateName, "idx", resIdx, "current", res.Current)
+				debugLog.Info("update current", "templateName", tmpl.TemplateName, "idx", resIdx,
+					"gvk", res.Current.GroupVersionKind(), "name", res.Current.GetName())
 			} else {
-				logger.Info("already have update current", "templatename", tmpl.TemplateName, "idx", resIdx, "current", res.Current)
+				debugLog.Info("already have update current", "templateName", tmpl.TemplateName, "idx", resIdx,
+					"gvk", res.Current.GroupVersionKind(), "name", res.Current.GetName())
 			}
 		}
 		exists++
@@ -216,15 +288,32 @@ func buildResourceValues(templates []*ResourceTemplateState) map[string]any {
 }
 
 // Apply a list of pre-rendered templates and set owner reference for
-// namespaced resources
-func applyTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Object, templates []*ResourceTemplateState) error {
+// namespaced resources. With dryRun, resources are applied using
+// server-side dry-run and the changes are stored in the resource Diff
+func applyTemplates(ctx context.Context, r ControllerDynClient, parent client.Object, templates []*ResourceTemplateState, dryRun bool) error {
 	var err error
 	var errorCnt = 0
 
 	logger := log.FromContext(ctx)
 
 	for _, tmpl := range templates {
-		for _, res := range tmpl.Resources {
+		// Apply with server-side apply and update metrics
+		apply := func(res *ResourceComposite, namespace *string) error {
+			spanCtx, span := tracer.Start(ctx, "applyResource", trace.WithAttributes(attrTemplate.String(tmpl.TemplateName)))
+			defer span.End()
+			start := time.Now()
+			result, err := patchUnstructured(spanCtx, r, res.Rendered, res.GVR, namespace, dryRun)
+			metricPatchApply.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Inc()
+			metricPatchApplyDuration.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Observe(time.Since(start).Seconds())
+			if err != nil {
+				metricPatchApplyErrs.WithLabelValues(tmpl.GatewayClassName, tmpl.TemplateName).Inc()
+			} else if dryRun {
+				res.Diff = diffResource(res.Current, result)
+			}
+			return err
+		}
+		for resIdx := range tmpl.Resources {
+			res := &tmpl.Resources[resIdx]
 			if res.Rendered == nil || res.GVR == nil {
 				// We do not yet have enough information to render/apply this resource
 				continue
@@ -237,19 +326,20 @@ func applyTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Ob
 					errorCnt++
 				} else {
 					ns := parent.GetNamespace()
-					err = patchUnstructured(ctx, r, res.Rendered, res.GVR, &ns)
+					err = apply(res, &ns)
 					if err != nil {
 						logger.Error(err, "cannot apply namespaced template", "templateName", tmpl.TemplateName)
 						errorCnt++
 					}
 				}
 			} else {
-				err = patchUnstructured(ctx, r, res.Rendered, res.GVR, nil)
+				err = apply(res, nil)
 				if err != nil {
 					logger.Error(err, "cannot apply cluster-scoped template", "templateName", tmpl.TemplateName)
 					errorCnt++
 				}
 			}
+			res.ApplyErr = err
 		}
 	}
 
@@ -259,6 +349,31 @@ func applyTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Ob
 	return nil
 }
 
+// Record Events for resources which could not be applied. Called once
+// after the render loop, such that only errors from the latest apply
+// are reported
+func recordApplyErrors(recorder record.EventRecorder, parent client.Object, templates []*ResourceTemplateState) {
+	for _, tmpl := range templates {
+		for resIdx := range tmpl.Resources {
+			res := &tmpl.Resources[resIdx]
+			if res.ApplyErr != nil {
+				recorder.Eventf(parent, corev1.EventTypeWarning, EventReasonApplyError,
+					"cannot apply %s/%s %q from template %q: %v", res.Rendered.GetAPIVersion(), res.Rendered.GetKind(),
+					res.Rendered.GetName(), tmpl.TemplateName, res.ApplyErr)
+			}
+		}
+	}
+}
+
+// Rendered resources as a multi-document YAML string, e.g. for logging
+func compositesToYaml(composites []ResourceComposite) string {
+	docs := make([]string, 0, len(composites))
+	for _, c := range composites {
+		docs = append(docs, helperToYaml(c.Rendered.Object))
+	}
+	return strings.Join(docs, "\n---\n")
+}
+
 // This function is made available to templates as 'toYaml'
 func helperToYaml(v interface{}) string {
 	data, err := sigsyaml.Marshal(v)
@@ -271,14 +386,13 @@ func helperToYaml(v interface{}) string {
 func templateRender(tmpl *template.Template, templateValues *TemplateValues) (*bytes.Buffer, error) {
 	var buffer bytes.Buffer
 
+	if templateValues.lookup != nil {
+		tmpl.Funcs(template.FuncMap{"lookup": templateValues.lookup.lookup})
+	}
 	if err := tmpl.Execute(io.Writer(&buffer), templateValues); err != nil {
 		return nil, err
 	}
 
-	// FIXME: These are convenient, but we should have a better logging design, i.e. it should be possible to enable rendering info only
-	fmt.Printf("Rendered:\n%s\n", buffer.Bytes())
-	fmt.Printf("Values:\n%+v\n", templateValues)
-
 	return &buffer, nil
 }
 
@@ -322,6 +436,20 @@ func template2Composite(r ControllerClient, tmpl *template.Template, tmplValues
 	return composites, nil
 }
 
+// Prepare a GatewayClassBlueprint for use in templates, i.e. with
+// metadata only since the spec holds the templates themselves
+func blueprintToMap(gwcb *gwcapi.GatewayClassBlueprint) (map[string]any, error) {
+	identity := gwcapi.GatewayClassBlueprint{ObjectMeta: *gwcb.ObjectMeta.DeepCopy()}
+	identity.ManagedFields = nil
+	mapObj, err := objectToMap(&identity)
+	if err != nil {
+		return nil, err
+	}
+	delete(mapObj, "spec")
+	delete(mapObj, "status")
+	return mapObj, nil
+}
+
 // Prepare a resource like Gateway or HTTPRoute for use in templates
 // by converting to map[string]any
 func objectToMap(obj runtime.Object) (map[string]any, error) {
@@ -8,7 +8,7 @@ import (
 
 func TestParseSingleTemplate(t *testing.T) {
 	template := "foo"
-	tmpl, err := parseSingleTemplate("foo", template)
+	tmpl, err := parseSingleTemplate("foo", "", template)
 	if tmpl == nil || err != nil {
 		t.Fatalf("Error parsing template %v", err)
 	}
@@ -42,7 +42,7 @@ t3data:
 func helperGetResourceState() ([]*ResourceTemplateState, error) {
 	templates := map[string]string{}
 	_ = yaml.Unmarshal([]byte(textTemplate), &templates)
-	return parseTemplates(templates)
+	return parseTemplates("test", "", templates)
 }
 
 func helperGetValues() *TemplateValues {
@@ -101,3 +101,27 @@ func TestTemplate2map(t *testing.T) {
 		t.Fatalf("Rendered template error, got %v, expected 't3name-foo3-2'", rawResources[2]["name"])
 	}
 }
+
+func TestParseTemplatesHelpers(t *testing.T) {
+	helpers := `{{ define "name" }}{{ .Values.name1 }}-shared{{ end }}{{ define "other" }}shared{{ end }}`
+	templates, err := parseTemplates("test", helpers, map[string]string{
+		"t1": `name: {{ include "name" . }}`,
+		"t2": `{{ define "other" }}own{{ end }}name: {{ template "other" }}`,
+	})
+	if err != nil {
+		t.Fatalf("Error parsing templates %v", err)
+	}
+	for idx, expected := range []string{"t1name-shared", "own"} {
+		res, err := template2maps(templates[idx].Template, helperGetValues())
+		if err != nil {
+			t.Fatalf("Error rendering template %v: %v", templates[idx].TemplateName, err)
+		}
+		if res[0]["name"] != expected {
+			t.Errorf("Template %v: got name %v, expected %v", templates[idx].TemplateName, res[0]["name"], expected)
+		}
+	}
+
+	if _, err := parseTemplates("test", `{{ define "name" }}`, map[string]string{"t1": "name: foo"}); err == nil {
+		t.Errorf("Expected error parsing invalid helpers")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// Tracer for reconcile pipeline spans. The global tracer provider is
+// a no-op unless tracing is configured, see pkg/tracing
+var tracer = otel.Tracer(string(selfapi.SelfControllerName))
+
+// Span attribute keys
+const (
+	attrNamespace    = attribute.Key("k8s.namespace.name")
+	attrName         = attribute.Key("bifrost.name")
+	attrGatewayClass = attribute.Key("bifrost.gatewayclass")
+	attrTemplate     = attribute.Key("bifrost.template")
+	attrGVR          = attribute.Key("bifrost.gvr")
+	attrAttempt      = attribute.Key("bifrost.attempt")
+	attrDryRun       = attribute.Key("bifrost.dryrun")
+)
+
+// Record error on span, if any
+func spanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// GVR as span attribute, formatted like an API path, e.g. 'apps/v1/deployments'
+func gvrAttribute(gvr *schema.GroupVersionResource) attribute.KeyValue {
+	return attrGVR.String(gvr.GroupVersion().String() + "/" + gvr.Resource)
+}
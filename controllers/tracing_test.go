@@ -0,0 +1,112 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	savedTracer := tracer
+	tracer = provider.Tracer("test")
+	defer func() { tracer = savedTracer }()
+
+	scheme := OfflineScheme()
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+	failing := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+			return errors.New("api server unavailable")
+		},
+	}).Build()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "tracing-test", Name: "missing"}}
+	reconcilers := []struct {
+		span       string
+		reconciler func(client.Client) reconcile.Reconciler
+	}{
+		{"reconcileGateway", func(c client.Client) reconcile.Reconciler {
+			return &GatewayReconciler{client: c, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+		}},
+		{"reconcileHTTPRoute", func(c client.Client) reconcile.Reconciler {
+			return &HTTPRouteReconciler{client: c, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+		}},
+	}
+	for _, tc := range reconcilers {
+		exporter.Reset()
+		if _, err := tc.reconciler(cl).Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("%s: cannot reconcile deleted resource: %v", tc.span, err)
+		}
+		if _, err := tc.reconciler(failing).Reconcile(context.Background(), req); err == nil {
+			t.Fatalf("%s: expected reconcile error", tc.span)
+		}
+
+		spans := exporter.GetSpans()
+		if len(spans) != 2 {
+			t.Fatalf("%s: got %d spans, expected 2", tc.span, len(spans))
+		}
+		for _, span := range spans {
+			if span.Name != tc.span {
+				t.Errorf("Got span %q, expected %q", span.Name, tc.span)
+			}
+			attrs := map[string]string{}
+			for _, attr := range span.Attributes {
+				attrs[string(attr.Key)] = attr.Value.Emit()
+			}
+			if attrs[string(attrNamespace)] != req.Namespace || attrs[string(attrName)] != req.Name {
+				t.Errorf("%s: got attributes %v, expected namespace and name of resource", tc.span, attrs)
+			}
+		}
+		if spans[0].Status.Code != codes.Unset {
+			t.Errorf("%s: got status %v for successful reconcile, expected unset", tc.span, spans[0].Status)
+		}
+		if spans[1].Status.Code != codes.Error || spans[1].Status.Description != "api server unavailable" {
+			t.Errorf("%s: got status %v for failed reconcile, expected error", tc.span, spans[1].Status)
+		}
+		if len(spans[1].Events) != 1 || spans[1].Events[0].Name != "exception" {
+			t.Errorf("%s: got events %v for failed reconcile, expected recorded error", tc.span, spans[1].Events)
+		}
+	}
+}
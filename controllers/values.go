@@ -0,0 +1,352 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/json"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Origin of an effective template value, see mergeValueSources
+type ValueOrigin struct {
+	// Dot-separated path of the value, e.g. 'tags.team'
+	Path string `json:"path"`
+
+	// Effective value
+	Value any `json:"value"`
+
+	// Source supplying the effective value
+	Source string `json:"source"`
+
+	// Values at the same path from sources with lower precedence,
+	// which were masked by the effective value. Ordered by
+	// precedence, lowest first
+	Masked []MaskedValue `json:"masked,omitempty"`
+
+	// Lists from sources with lower precedence, which were merged
+	// into the effective value using a merge strategy. Ordered by
+	// precedence, lowest first
+	Merged []MaskedValue `json:"merged,omitempty"`
+}
+
+// A value masked by a value from a source with higher precedence
+type MaskedValue struct {
+	Source string `json:"source"`
+
+	// Path of masked value, if different from the effective value,
+	// e.g. when a list was replaced by a map
+	Path string `json:"path,omitempty"`
+
+	Value any `json:"value"`
+}
+
+// A leaf value and its path. Lists and empty maps are considered leaves
+type leafValue struct {
+	path  []string
+	value any
+}
+
+// Merge value sources in order, i.e. later sources take precedence
+// over earlier sources. Lists are merged using the given strategies,
+// see valueMerger. With track, the origin of each effective leaf
+// value is returned, sorted by path. Type conflicts are returned as
+// human readable descriptions. IMPORTANT: All values are Unmarshalled
+// and hence we will not be modifying original K8s resources
+//
+//nolint:gocyclo // Tracking of origins is inherently branchy
+func mergeValueSources(sources []valueSource, strategies []gwcapi.ValueMergeStrategy,
+	track bool) (map[string]any, []ValueOrigin, []string, error) {
+	values := map[string]any{}
+	origins := map[string]*ValueOrigin{}
+	merger := newValueMerger(strategies)
+
+	for _, src := range sources {
+		if src.Values == nil {
+			continue
+		}
+		newvals := map[string]any{}
+		if err := json.Unmarshal(src.Values.Raw, &newvals); err != nil {
+			return nil, nil, nil, fmt.Errorf("while processing %s: cannot unmarshal values: %w", src.Origin, err)
+		}
+		merger.origin = src.Origin
+		merged, ok := merger.merge(nil, values, newvals).(map[string]any)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("while processing %s: cannot merge values", src.Origin)
+		}
+		values = merged
+
+		if !track {
+			continue
+		}
+		for _, leaf := range leafValues(nil, newvals) {
+			effective, found := valueAtPath(values, leaf.path)
+			path := strings.Join(leaf.path, ".")
+			if leaf.value == nil {
+				// Tombstone, i.e. value and values below it were removed
+				for _, removedPath := range sortedKeys(origins) {
+					if removedPath == path || strings.HasPrefix(removedPath, path+".") {
+						delete(origins, removedPath)
+					}
+				}
+				continue
+			}
+			if prev, isMerged := origins[path]; found && isMerged && merger.merges(path, prev.Value, leaf.value) {
+				// List merged with list from sources with lower precedence
+				merged := append(append([]MaskedValue{}, prev.Merged...), MaskedValue{Source: prev.Source, Value: prev.Value})
+				origins[path] = &ValueOrigin{Path: path, Value: effective, Source: src.Origin, Masked: prev.Masked, Merged: merged}
+				continue
+			}
+			if !found || !reflect.DeepEqual(effective, leaf.value) {
+				continue // Value from this source did not take effect, e.g. due to a type conflict
+			}
+			newOrigin := &ValueOrigin{Path: path, Value: leaf.value, Source: src.Origin}
+			// Values at this path, or at paths of replaced
+			// ancestor or descendant values, are masked
+			for _, maskedPath := range sortedKeys(origins) {
+				if maskedPath == path || strings.HasPrefix(path, maskedPath+".") || strings.HasPrefix(maskedPath, path+".") {
+					masked := origins[maskedPath]
+					newOrigin.Masked = append(newOrigin.Masked, masked.Masked...)
+					maskedValue := MaskedValue{Source: masked.Source, Value: masked.Value}
+					if maskedPath != path {
+						maskedValue.Path = maskedPath
+					}
+					newOrigin.Masked = append(newOrigin.Masked, maskedValue)
+					delete(origins, maskedPath)
+				}
+			}
+			origins[path] = newOrigin
+		}
+	}
+
+	if !track {
+		return values, nil, merger.conflicts, nil
+	}
+
+	// Only report origins of leaves still present, e.g. a leaf may
+	// have been replaced by a map from a later source
+	effective := make([]ValueOrigin, 0, len(origins))
+	for _, leaf := range leafValues(nil, values) {
+		if origin, found := origins[strings.Join(leaf.path, ".")]; found {
+			effective = append(effective, *origin)
+		}
+	}
+	sort.Slice(effective, func(i, j int) bool { return effective[i].Path < effective[j].Path })
+	return values, effective, merger.conflicts, nil
+}
+
+// Merges values with per-path strategies for lists. Maps are
+// deep-merged and other values replaced, unless a strategy is
+// defined for the path of a list. A null value is a tombstone, i.e.
+// the key is removed. Type conflicts, e.g. a map and a string, keep
+// the value with lower precedence and are collected
+type valueMerger struct {
+	strategies map[string]gwcapi.ValueMergeStrategy
+
+	// Origin of the values currently merged, used in conflict descriptions
+	origin string
+
+	conflicts []string
+}
+
+func newValueMerger(strategies []gwcapi.ValueMergeStrategy) *valueMerger {
+	m := &valueMerger{strategies: map[string]gwcapi.ValueMergeStrategy{}}
+	for _, strategy := range strategies {
+		m.strategies[strategy.Path] = strategy
+	}
+	return m
+}
+
+// Deep merge, with 'b' overwriting values in 'a'. Maps in 'a' are
+// modified in place. x and y are concrete versions of a and b
+func (m *valueMerger) merge(path []string, a, b any) any {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok {
+			m.conflict(path, a, b)
+			return a
+		}
+		for _, k := range sortedKeys(y) { // Copy from 'b' (represented by 'y') into 'a', sorted for predictable conflicts
+			vy := y[k]
+			if vy == nil {
+				delete(x, k) // Tombstone
+			} else if va, ok := x[k]; ok {
+				x[k] = m.merge(append(append([]string{}, path...), k), va, vy)
+			} else {
+				x[k] = removeTombstones(vy)
+			}
+		}
+		return a
+	case []any:
+		strategy, found := m.strategies[strings.Join(path, ".")]
+		if !found || strategy.Strategy == gwcapi.ValueMergeStrategyReplace {
+			return removeTombstones(b)
+		}
+		y, ok := b.([]any)
+		if !ok {
+			m.conflict(path, a, b)
+			return a
+		}
+		return m.mergeList(path, strategy, x, y)
+	default:
+		return removeTombstones(b)
+	}
+}
+
+func (m *valueMerger) mergeList(path []string, strategy gwcapi.ValueMergeStrategy, x, y []any) []any {
+	merged := append([]any{}, x...)
+	switch strategy.Strategy {
+	case gwcapi.ValueMergeStrategyAppend:
+		merged = append(merged, y...)
+	case gwcapi.ValueMergeStrategyUniqueAppend:
+		for _, vy := range y {
+			if !slices.ContainsFunc(merged, func(va any) bool { return reflect.DeepEqual(va, vy) }) {
+				merged = append(merged, vy)
+			}
+		}
+	case gwcapi.ValueMergeStrategyMergeByKey:
+		for _, vy := range y {
+			my, ok := vy.(map[string]any)
+			if !ok || my[strategy.Key] == nil {
+				m.conflicts = append(m.conflicts, fmt.Sprintf("%s: list item from %s has no key %q",
+					strings.Join(path, "."), m.origin, strategy.Key))
+				continue
+			}
+			idx := slices.IndexFunc(merged, func(va any) bool {
+				ma, ok := va.(map[string]any)
+				return ok && reflect.DeepEqual(ma[strategy.Key], my[strategy.Key])
+			})
+			if idx < 0 {
+				merged = append(merged, vy)
+			} else {
+				merged[idx] = m.merge(path, merged[idx], vy)
+			}
+		}
+	}
+	return merged
+}
+
+func (m *valueMerger) conflict(path []string, a, b any) {
+	m.conflicts = append(m.conflicts, fmt.Sprintf("%s: cannot merge %s from %s into %s, value ignored",
+		strings.Join(path, "."), valueTypeName(b), m.origin, valueTypeName(a)))
+}
+
+// Remove keys with null values from maps, i.e. tombstones without
+// values to remove
+func removeTombstones(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	for k, sub := range m {
+		if sub == nil {
+			delete(m, k)
+		} else {
+			m[k] = removeTombstones(sub)
+		}
+	}
+	return m
+}
+
+// Name of the type of an unmarshalled JSON value
+func valueTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "map"
+	case []any:
+		return "list"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+// Whether lists a and b at path are merged rather than b replacing a
+func (m *valueMerger) merges(path string, a, b any) bool {
+	strategy, found := m.strategies[path]
+	if !found || strategy.Strategy == gwcapi.ValueMergeStrategyReplace {
+		return false
+	}
+	_, aList := a.([]any)
+	_, bList := b.([]any)
+	return aList && bList
+}
+
+// All leaf values of a value, recursing into non-empty maps
+func leafValues(path []string, value any) []leafValue {
+	m, ok := value.(map[string]any)
+	if !ok || (len(m) == 0 && len(path) > 0) {
+		return []leafValue{{path, value}}
+	}
+	var leaves []leafValue
+	for key, sub := range m {
+		subPath := append(append([]string{}, path...), key)
+		leaves = append(leaves, leafValues(subPath, sub)...)
+	}
+	return leaves
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Lookup value at path
+func valueAtPath(values map[string]any, path []string) (any, bool) {
+	var val any = values
+	for _, key := range path {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if val, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func TestMergeValueSources(t *testing.T) {
+	sources := []valueSource{
+		{Origin: "blueprint (default)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 1, "tags": [], "region": "eu-north-1"}`)}},
+		{Origin: "global (default)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 2}`)}},
+		{Origin: "gateway (default)", Values: nil},
+		{Origin: "gateway (override)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 3, "tags": {"team": "foo"}}`)}},
+		{Origin: "blueprint (override)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"tags": "conflict"}`)}},
+	}
+
+	values, origins, conflicts, err := mergeValueSources(sources, nil, true)
+	if err != nil {
+		t.Fatalf("Cannot merge values: %v", err)
+	}
+	if values["replicas"] != int64(3) {
+		t.Fatalf("Merged value error, got %v, expected 3", values["replicas"])
+	}
+	if len(origins) != 3 {
+		t.Fatalf("Origin count, got %v, expected 3: %+v", len(origins), origins)
+	}
+
+	// Sorted by path
+	region, replicas, team := origins[0], origins[1], origins[2]
+	if region.Path != "region" || region.Source != "blueprint (default)" || len(region.Masked) != 0 {
+		t.Fatalf("Origin of value not overridden, got %+v", region)
+	}
+	if replicas.Path != "replicas" || replicas.Source != "gateway (override)" || len(replicas.Masked) != 2 ||
+		replicas.Masked[0].Source != "blueprint (default)" || replicas.Masked[1].Source != "global (default)" {
+		t.Fatalf("Origin of overridden value, got %+v", replicas)
+	}
+	if team.Path != "tags.team" || team.Source != "gateway (override)" || len(team.Masked) != 1 ||
+		team.Masked[0].Path != "tags" {
+		t.Fatalf("Origin of value replacing list and with type conflict, got %+v", team)
+	}
+
+	if len(conflicts) != 1 || conflicts[0] != "tags: cannot merge string from blueprint (override) into map, value ignored" {
+		t.Fatalf("Conflicts error, got %v", conflicts)
+	}
+
+	if _, origins, _, _ = mergeValueSources(sources, nil, false); origins != nil {
+		t.Fatalf("Origins returned without tracking, got %+v", origins)
+	}
+}
+
+func TestMergeValueSourcesStrategies(t *testing.T) {
+	strategies := []gwcapi.ValueMergeStrategy{
+		{Path: "cidrs", Strategy: gwcapi.ValueMergeStrategyAppend},
+		{Path: "lb.zones", Strategy: gwcapi.ValueMergeStrategyUniqueAppend},
+		{Path: "listeners", Strategy: gwcapi.ValueMergeStrategyMergeByKey, Key: "name"},
+		{Path: "replaced", Strategy: gwcapi.ValueMergeStrategyReplace},
+	}
+	sources := []valueSource{
+		{Origin: "blueprint (default)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"cidrs": ["10.0.0.0/8"], "lb": {"zones": ["a", "b"]},
+			"listeners": [{"name": "http", "port": 80}], "replaced": [1], "other": [1]}`)}},
+		{Origin: "global (default)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"cidrs": ["192.168.0.0/16"], "lb": {"zones": ["b", "c"]},
+			"listeners": [{"name": "http", "port": 8080}, {"name": "https", "port": 443}], "replaced": [2], "other": [2]}`)}},
+		{Origin: "gateway (default)", Values: &apiextensionsv1.JSON{Raw: []byte(`{"cidrs": "conflict", "listeners": [{"port": 1}]}`)}},
+	}
+
+	values, origins, conflicts, err := mergeValueSources(sources, strategies, true)
+	if err != nil {
+		t.Fatalf("Cannot merge values: %v", err)
+	}
+	expected := map[string]any{
+		"cidrs": []any{"10.0.0.0/8", "192.168.0.0/16"},
+		"lb":    map[string]any{"zones": []any{"a", "b", "c"}},
+		"listeners": []any{
+			map[string]any{"name": "http", "port": int64(8080)},
+			map[string]any{"name": "https", "port": int64(443)}},
+		"replaced": []any{int64(2)},
+		"other":    []any{int64(2)},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Merged values error, got %+v", values)
+	}
+	if len(conflicts) != 2 || !strings.HasPrefix(conflicts[0], "cidrs: cannot merge string") ||
+		!strings.HasPrefix(conflicts[1], `listeners: list item from gateway (default) has no key "name"`) {
+		t.Fatalf("Conflicts error, got %v", conflicts)
+	}
+
+	// Origins of merged lists include all contributing sources
+	for _, origin := range origins {
+		if origin.Path == "cidrs" {
+			if origin.Source != "global (default)" || len(origin.Merged) != 1 || origin.Merged[0].Source != "blueprint (default)" {
+				t.Fatalf("Origin of merged list, got %+v", origin)
+			}
+			return
+		}
+	}
+	t.Fatalf("Origin of merged list not found, got %+v", origins)
+}
+
+// A null value in defaults or overrides at any level of the
+// hierarchy removes the value inherited from lower precedence
+func TestLookupValuesTombstones(t *testing.T) {
+	savedNamespace := ControllerNamespace
+	ControllerNamespace = "bifrost-system"
+	defer func() { ControllerNamespace = savedNamespace }()
+
+	gwcRef := &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "GatewayClass", Name: "gwc"}
+	nsRef := &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: "", Kind: "Namespace", Name: "foo"}
+	gwRef := &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"}
+	rtRef := &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Name: "rt"}
+
+	levels := map[string]func(values gwcapi.TemplateValues) client.Object{
+		"ClusterGatewayClassConfig": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.ClusterGatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+				Spec: gwcapi.ClusterGatewayClassConfigSpec{TemplateValues: values}}
+		},
+		"global GatewayClassConfig": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "bifrost-system"},
+				Spec: gwcapi.GatewayClassConfigSpec{TemplateValues: values, TargetRef: gwcRef}}
+		},
+		"GatewayClassConfig targeting namespace": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+				Spec: gwcapi.GatewayClassConfigSpec{TemplateValues: values, TargetRef: nsRef}}
+		},
+		"GatewayClassConfig targeting GatewayClass": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+				Spec: gwcapi.GatewayClassConfigSpec{TemplateValues: values, TargetRef: gwcRef}}
+		},
+		"GatewayConfig targeting namespace": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+				Spec: gwcapi.GatewayConfigSpec{TemplateValues: values, TargetRef: nsRef}}
+		},
+		"GatewayConfig targeting Gateway": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+				Spec: gwcapi.GatewayConfigSpec{TemplateValues: values, TargetRef: gwRef}}
+		},
+		"GatewayConfig targeting HTTPRoute": func(values gwcapi.TemplateValues) client.Object {
+			return &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+				Spec: gwcapi.GatewayConfigSpec{TemplateValues: values, TargetRef: rtRef}}
+		},
+	}
+	raw := func(s string) *apiextensionsv1.JSON { return &apiextensionsv1.JSON{Raw: []byte(s)} }
+	cases := []struct {
+		name     string
+		values   gwcapi.TemplateValues
+		expected map[string]any
+	}{
+		{"default", gwcapi.TemplateValues{Default: raw(`{"hpa": null, "tags": {"team": null}}`)},
+			map[string]any{"replicas": int64(1), "tags": map[string]any{"env": "prod"}}},
+		{"override", gwcapi.TemplateValues{Override: raw(`{"hpa": null, "tags": {"team": null}}`)},
+			map[string]any{"replicas": int64(1), "tags": map[string]any{"env": "prod"}}},
+		{"nested", gwcapi.TemplateValues{Default: raw(`{"hpa": {"minReplicas": null}, "unknown": {"key": null}}`)},
+			map[string]any{"replicas": int64(1), "hpa": map[string]any{"maxReplicas": int64(5)},
+				"tags": map[string]any{"env": "prod", "team": "a"}, "unknown": map[string]any{}}},
+	}
+
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "gwcb"}}
+	gwcb.Spec.Values.Default = raw(`{"replicas": 1, "hpa": {"minReplicas": 2, "maxReplicas": 5}, "tags": {"env": "prod", "team": "a"}}`)
+	route := &types.NamespacedName{Namespace: "foo", Name: "rt"}
+	scheme := OfflineScheme()
+	for level, policy := range levels {
+		for _, tc := range cases {
+			objs := []client.Object{
+				&gatewayapi.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "gwc"}},
+				&gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "foo"}},
+				&gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: "foo"}},
+				policy(tc.values),
+			}
+			r := &offlineClient{
+				client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+				scheme: scheme,
+			}
+			lookup, err := lookupValues(context.Background(), r, "gwc", gwcb, "foo", "gw", route)
+			if err != nil {
+				t.Fatalf("%s %s: cannot lookup values: %v", level, tc.name, err)
+			}
+			if len(lookup.policies) != 1 {
+				t.Fatalf("%s %s: policy not used, got %v policies", level, tc.name, len(lookup.policies))
+			}
+			if !reflect.DeepEqual(lookup.values, tc.expected) {
+				t.Errorf("%s %s: got %+v, expected %+v", level, tc.name, lookup.values, tc.expected)
+			}
+		}
+	}
+}
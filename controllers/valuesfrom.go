@@ -0,0 +1,241 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Objects the manager client must read from the API server rather
+// than its cache. ConfigMaps and Secrets are only watched as metadata
+// to avoid caching all of them
+func UncachedObjects() []client.Object {
+	return []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}}
+}
+
+// A ConfigMap, Secret or key referenced with 'valuesFrom' and not
+// marked optional does not exist
+type missingValuesReferenceError struct {
+	origin string
+	kind   string
+}
+
+func (e *missingValuesReferenceError) Error() string {
+	return fmt.Sprintf("while processing %s: referenced %s or key not found", e.origin, e.kind)
+}
+
+// Value sources for the defaults or overrides of an object, i.e. the
+// inline values followed by values from ConfigMaps and Secrets
+// referenced with 'valuesFrom'. References are resolved in the
+// namespace of the object, or the controller namespace for
+// cluster-scoped objects
+func templateValueSources(ctx context.Context, r ControllerClient, kind string, obj client.Object,
+	values *gwcapi.TemplateValues, override bool) ([]valueSource, error) {
+	field, inline := "default", values.Default
+	if override {
+		field, inline = "override", values.Override
+	}
+	sources := []valueSource{{Origin: valueOrigin(kind, obj, field), Values: inline}}
+
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = ControllerNamespace
+	}
+	for idx := range values.ValuesFrom {
+		ref := &values.ValuesFrom[idx]
+		if ref.Override != override {
+			continue
+		}
+		origin := valueOrigin(kind, obj, fmt.Sprintf("%s from %s %s/%s key %s", field, ref.Kind, namespace, ref.Name, ref.Key))
+		data, found, err := lookupValuesReference(ctx, r, namespace, ref)
+		if err != nil {
+			return nil, fmt.Errorf("while processing %s: %w", origin, err)
+		}
+		if !found {
+			if ref.Optional {
+				continue
+			}
+			return nil, &missingValuesReferenceError{origin, ref.Kind}
+		}
+		raw, err := referencedValues(ref, data)
+		if err != nil {
+			return nil, fmt.Errorf("while processing %s: %w", origin, err)
+		}
+		sources = append(sources, valueSource{Origin: origin, Values: raw, Sensitive: ref.Kind == "Secret"})
+	}
+	return sources, nil
+}
+
+// Lookup the data of the key referenced by a ValuesReference. Returns
+// false if the ConfigMap/Secret or key does not exist
+func lookupValuesReference(ctx context.Context, r ControllerClient, namespace string, ref *gwcapi.ValuesReference) ([]byte, bool, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	var data []byte
+	var found bool
+	switch ref.Kind {
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err := r.Client().Get(ctx, key, &cm); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		var str string
+		if str, found = cm.Data[ref.Key]; found {
+			data = []byte(str)
+		} else {
+			data, found = cm.BinaryData[ref.Key]
+		}
+	case "Secret":
+		var secret corev1.Secret
+		if err := r.Client().Get(ctx, key, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		data, found = secret.Data[ref.Key]
+		if !found {
+			// StringData is never returned by the API server, but may be set in offline input
+			var str string
+			if str, found = secret.StringData[ref.Key]; found {
+				data = []byte(str)
+			}
+		}
+	default:
+		return nil, false, fmt.Errorf("unsupported kind %q", ref.Kind)
+	}
+	return data, found, nil
+}
+
+// Convert referenced data to values. With a target path, the data is
+// placed as a string at the path, otherwise it is parsed as a YAML map
+func referencedValues(ref *gwcapi.ValuesReference, data []byte) (*apiextensionsv1.JSON, error) {
+	values := map[string]any{}
+	if ref.TargetPath != "" {
+		keys := strings.Split(ref.TargetPath, ".")
+		m := values
+		for _, key := range keys[:len(keys)-1] {
+			sub := map[string]any{}
+			m[key] = sub
+			m = sub
+		}
+		m[keys[len(keys)-1]] = string(data)
+	} else if err := sigsyaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cannot parse values: %w", err)
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return &apiextensionsv1.JSON{Raw: raw}, nil
+}
+
+// Paths of the leaf values supplied by sensitive value sources,
+// i.e. values from Secrets, which must be redacted in logs and events
+func sensitivePaths(sources []valueSource) ([]string, error) {
+	var paths []string
+	for _, src := range sources {
+		if !src.Sensitive || src.Values == nil {
+			continue
+		}
+		values := map[string]any{}
+		if err := json.Unmarshal(src.Values.Raw, &values); err != nil {
+			return nil, fmt.Errorf("while processing %s: cannot unmarshal values: %w", src.Origin, err)
+		}
+		for _, leaf := range leafValues(nil, values) {
+			paths = append(paths, strings.Join(leaf.path, "."))
+		}
+	}
+	return paths, nil
+}
+
+// Whether a ConfigMap or Secret is referenced with 'valuesFrom' by a
+// policy or GatewayClassBlueprint, see templateValueSources
+func isValuesFromSource(ctx context.Context, r ControllerClient, kind string, obj client.Object) (bool, error) {
+	var values []*gwcapi.TemplateValues
+
+	var gwccList gwcapi.GatewayClassConfigList
+	if err := r.Client().List(ctx, &gwccList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return false, err
+	}
+	for idx := range gwccList.Items {
+		values = append(values, &gwccList.Items[idx].Spec.TemplateValues)
+	}
+	var gwcList gwcapi.GatewayConfigList
+	if err := r.Client().List(ctx, &gwcList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return false, err
+	}
+	for idx := range gwcList.Items {
+		values = append(values, &gwcList.Items[idx].Spec.TemplateValues)
+	}
+
+	// Cluster-scoped resources reference the controller namespace
+	if obj.GetNamespace() == ControllerNamespace {
+		var gwcbList gwcapi.GatewayClassBlueprintList
+		if err := r.Client().List(ctx, &gwcbList); err != nil {
+			return false, err
+		}
+		for idx := range gwcbList.Items {
+			values = append(values, &gwcbList.Items[idx].Spec.Values)
+		}
+		var cgwccList gwcapi.ClusterGatewayClassConfigList
+		if err := r.Client().List(ctx, &cgwccList); err != nil {
+			return false, err
+		}
+		for idx := range cgwccList.Items {
+			values = append(values, &cgwccList.Items[idx].Spec.TemplateValues)
+		}
+	}
+
+	for _, v := range values {
+		for _, ref := range v.ValuesFrom {
+			if ref.Kind == kind && ref.Name == obj.GetName() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
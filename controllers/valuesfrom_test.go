@@ -0,0 +1,136 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func TestLookupValuesFrom(t *testing.T) {
+	savedNamespace := ControllerNamespace
+	ControllerNamespace = "bifrost-system"
+	defer func() { ControllerNamespace = savedNamespace }()
+
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "gwcb"}}
+	gwcb.Spec.Values.Default = &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 1, "tags": {"env": "dev"}}`)}
+	gwcb.Spec.Values.ValuesFrom = []gwcapi.ValuesReference{
+		{Kind: "ConfigMap", Name: "global", Key: "values.yaml"},
+	}
+	gwc := &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "foo"},
+		Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gatewayapiv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"},
+			TemplateValues: gwcapi.TemplateValues{
+				Default: &apiextensionsv1.JSON{Raw: []byte(`{"replicas": 2, "tags": {"team": "inline"}}`)},
+				ValuesFrom: []gwcapi.ValuesReference{
+					{Kind: "ConfigMap", Name: "settings", Key: "tags", TargetPath: "tags.team"},
+					{Kind: "Secret", Name: "waf", Key: "arn", TargetPath: "waf.aclArn", Override: true},
+					{Kind: "Secret", Name: "missing", Key: "token", Optional: true},
+				},
+			},
+		}}
+	objs := []client.Object{
+		&gatewayapi.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "gwc"}},
+		&gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "foo"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "global", Namespace: "bifrost-system"},
+			Data: map[string]string{"values.yaml": "tags:\n  env: prod\nregion: eu-north-1\n"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "foo"},
+			Data: map[string]string{"tags": "from-configmap"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "foo"},
+			Data: map[string][]byte{"arn": []byte("arn:aws:wafv2:secret")}},
+		gwcb,
+		gwc,
+	}
+	scheme := OfflineScheme()
+	r := &offlineClient{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		scheme: scheme,
+	}
+
+	lookup, err := lookupValues(context.Background(), r, "gwc", gwcb, "foo", "gw", nil)
+	if err != nil {
+		t.Fatalf("Cannot lookup values: %v", err)
+	}
+	expected := map[string]any{
+		"replicas": int64(2),
+		"region":   "eu-north-1",
+		"tags":     map[string]any{"env": "prod", "team": "from-configmap"},
+		"waf":      map[string]any{"aclArn": "arn:aws:wafv2:secret"},
+	}
+	if !reflect.DeepEqual(lookup.values, expected) {
+		t.Errorf("Got values %+v, expected %+v", lookup.values, expected)
+	}
+	if !reflect.DeepEqual(lookup.sensitivePaths, []string{"waf.aclArn"}) {
+		t.Errorf("Got sensitive paths %v, expected [waf.aclArn]", lookup.sensitivePaths)
+	}
+
+	for _, tc := range []struct {
+		kind, namespace, name string
+		expected              bool
+	}{
+		{"Secret", "foo", "waf", true},
+		{"ConfigMap", "foo", "waf", false},
+		{"Secret", "bar", "waf", false},
+		{"ConfigMap", "bifrost-system", "global", true},
+	} {
+		obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: tc.name, Namespace: tc.namespace}}
+		referenced, err := isValuesFromSource(context.Background(), r, tc.kind, obj)
+		if err != nil {
+			t.Fatalf("Cannot lookup references: %v", err)
+		}
+		if referenced != tc.expected {
+			t.Errorf("%s %s/%s: got referenced %v, expected %v", tc.kind, tc.namespace, tc.name, referenced, tc.expected)
+		}
+	}
+
+	// References not marked optional must exist
+	gwc.Spec.ValuesFrom[2].Optional = false
+	if err := r.client.Update(context.Background(), gwc); err != nil {
+		t.Fatalf("Cannot update GatewayConfig: %v", err)
+	}
+	var missing *missingValuesReferenceError
+	if _, err := lookupValues(context.Background(), r, "gwc", gwcb, "foo", "gw", nil); !errors.As(err, &missing) {
+		t.Errorf("Got error %v, expected error for missing Secret", err)
+	}
+}
@@ -32,8 +32,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -41,17 +43,21 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	cache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	gateway "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	gatewaytv2dkv1a1 "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -67,6 +73,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(gateway.Install(scheme))
+	utilruntime.Must(gatewayv1b1.Install(scheme))
 	utilruntime.Must(gatewaytv2dkv1a1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -76,6 +83,9 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var syncPeriodArg string
+	var redactValuePaths string
+	var templateLookupKinds string
+	var tracingOpts tracing.Options
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -83,6 +93,12 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&syncPeriodArg, "sync-period", "120s", "The period between non event-driven resynchronizations")
 	flag.StringVar(&controllers.ControllerNamespace, "controller-namespace", "bifrost-gateway-controller-system", "The namespace the controller will watch for global policies")
+	flag.StringVar(&redactValuePaths, "redact-value-paths", "", "Comma-separated list of dot-separated template value paths, e.g. 'aws.secretKey', which are redacted in debug logs")
+	flag.StringVar(&templateLookupKinds, "template-lookup-kinds", "", "Comma-separated list of kinds, e.g. 'ConfigMap,VPC.ec2.aws.upbound.io', which templates of all blueprints may read with the 'lookup' function")
+	flag.StringVar(&controllers.BlueprintSourceDir, "blueprint-source-dir", "", "Directory of GatewayClassBlueprint files read by blueprints with a 'file' source. File sources are disabled if not set")
+	flag.StringVar(&tracingOpts.Endpoint, "tracing-otlp-endpoint", "", "OTLP/gRPC endpoint for OpenTelemetry traces, e.g. 'otel-collector:4317'. Tracing is disabled if not set")
+	flag.BoolVar(&tracingOpts.Insecure, "tracing-otlp-insecure", false, "Disable TLS towards the OTLP/gRPC endpoint")
+	flag.Float64Var(&tracingOpts.SampleRatio, "tracing-sample-ratio", 1.0, "Fraction of reconciles being traced, between 0 and 1")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -92,12 +108,27 @@ func main() {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	setupLog.Info("bifrost-gateway-controller", "version", version, "build-date", date, "commit", commit)
 
+	if redactValuePaths != "" {
+		controllers.RedactedValuePaths = strings.Split(redactValuePaths, ",")
+	}
+	if templateLookupKinds != "" {
+		for _, kind := range strings.Split(templateLookupKinds, ",") {
+			controllers.TemplateLookupKinds = append(controllers.TemplateLookupKinds, schema.ParseGroupKind(kind))
+		}
+	}
+
 	syncPeriod, err := time.ParseDuration(syncPeriodArg)
 	if err != nil {
 		setupLog.Error(err, "unable to parse 'sync-period' argument")
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts, version)
+	if err != nil {
+		setupLog.Error(err, "unable to setup tracing")
+		os.Exit(1)
+	}
+
 	config := ctrl.GetConfigOrDie()
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme: scheme,
@@ -109,6 +140,9 @@ func main() {
 		Cache: cache.Options{
 			SyncPeriod: &syncPeriod,
 		},
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: controllers.UncachedObjects()},
+		},
 		LeaderElection:   enableLeaderElection,
 		LeaderElectionID: "71264cc8.bifrost-gateway-controller.tv2.dk",
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
@@ -159,4 +193,8 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
 }
@@ -38,4 +38,18 @@ import (
 
 const (
 	SelfControllerName gatewayapi.GatewayController = "github.com/tv2-oss/bifrost-gateway-controller"
+
+	// Annotation on Gateway and HTTPRoute resources holding a JSON
+	// inventory of the child resources created from templates
+	InventoryAnnotation = "gateway.tv2.dk/inventory"
+
+	// Annotation on Gateway and HTTPRoute resources which, when set
+	// to "true", enables logging of template debug information such
+	// as rendered resources and template values for that resource
+	DebugAnnotation = "gateway.tv2.dk/debug"
+
+	// Annotation on Gateway resources which, when set to "true",
+	// makes the controller apply child resources with server-side
+	// dry-run only and report the changes that would have been made
+	DryRunAnnotation = "gateway.tv2.dk/dry-run"
 )
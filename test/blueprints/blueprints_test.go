@@ -0,0 +1,145 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Golden-file tests of the blueprints shipped in `blueprints/`. Each
+// test case renders a blueprint with the fixtures found in
+// `testdata/<case>/input.yaml` and compares the result with
+// `testdata/<case>/golden.yaml`. Optional fake child resources are
+// read from `testdata/<case>/resources.yaml`.
+//
+// Update golden files after blueprint changes with:
+//
+//	go test ./test/blueprints -update
+package blueprints
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var testCases = []struct {
+	name string
+
+	// Files with GatewayClass, GatewayClassBlueprint and
+	// policies, relative to the test directory
+	files []string
+}{
+	{
+		name: "contour-istio",
+		files: []string{
+			"../../blueprints/contour-istio/gatewayclass-contour-istio.yaml",
+			"../../blueprints/contour-istio/gatewayclassblueprint-contour-istio.yaml",
+		},
+	},
+	{
+		name: "contour-istio-cert",
+		files: []string{
+			"../../blueprints/contour-istio/gatewayclass-contour-istio-cert.yaml",
+			"../../blueprints/contour-istio/gatewayclassblueprint-contour-istio-cert.yaml",
+		},
+	},
+	{
+		name: "aws-alb-crossplane",
+		files: []string{
+			"../../blueprints/aws-alb-crossplane/gatewayclass-aws-alb-crossplane.yaml",
+			"../../blueprints/aws-alb-crossplane/gatewayclassblueprint-aws-alb-crossplane.yaml",
+			"../../test-data/gatewayclassconfig-aws-alb-crossplane-dev-env.yaml",
+		},
+	},
+}
+
+func TestBlueprints(t *testing.T) {
+	controllers.ControllerNamespace = "bifrost-gateway-controller-system"
+	scheme := controllers.OfflineScheme()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			caseDir := filepath.Join("testdata", tc.name)
+			input := controllers.OfflineInput{}
+
+			for _, fname := range append(tc.files, filepath.Join(caseDir, "input.yaml")) {
+				data, err := os.ReadFile(fname)
+				if err != nil {
+					t.Fatalf("Cannot read fixture: %v", err)
+				}
+				objs, err := controllers.DecodeOfflineObjects(scheme, data)
+				if err != nil {
+					t.Fatalf("Cannot decode %s: %v", fname, err)
+				}
+				input.Objects = append(input.Objects, objs...)
+			}
+
+			data, err := os.ReadFile(filepath.Join(caseDir, "resources.yaml"))
+			if err == nil {
+				if err = sigsyaml.Unmarshal(data, &input.Resources); err != nil {
+					t.Fatalf("Cannot decode fake resources: %v", err)
+				}
+			} else if !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("Cannot read fake resources: %v", err)
+			}
+
+			results, err := controllers.RenderOffline(context.Background(), &input)
+			if err != nil {
+				t.Fatalf("Cannot render blueprint: %v", err)
+			}
+			var rendered bytes.Buffer
+			if err = controllers.WriteOfflineResults(&rendered, results); err != nil {
+				t.Fatalf("Cannot write rendered resources: %v", err)
+			}
+
+			goldenFile := filepath.Join(caseDir, "golden.yaml")
+			if *update {
+				if err = os.WriteFile(goldenFile, rendered.Bytes(), 0o600); err != nil {
+					t.Fatalf("Cannot update golden file: %v", err)
+				}
+			}
+			golden, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("Cannot read golden file, use '-update' to create it: %v", err)
+			}
+			if diff := cmp.Diff(string(golden), rendered.String()); diff != "" {
+				t.Fatalf("Rendered resources differ from %s, use '-update' if intended (-golden +rendered):\n%s", goldenFile, diff)
+			}
+		})
+	}
+}